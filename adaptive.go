@@ -0,0 +1,135 @@
+package main
+
+import (
+	"log/slog"
+	"sort"
+	"time"
+)
+
+const (
+	// adaptiveSamples is how many recent save intervals the adaptive interval learns from
+	adaptiveSamples = 50
+	// adaptiveMinSamples is how many intervals are needed before adapting;
+	// until then alarm_interval is used as it is
+	adaptiveMinSamples = 5
+)
+
+// adaptiveInterval works out the time before the first alarm from the player's
+// recent save intervals (oldest first) and those of the current session:
+//   - normally the typical (median) interval
+//   - tighter right after a gap much longer than usual, when the player seems
+//     to have forgotten about saving
+//   - looser during a stretch of rapid saves, when the player clearly has it in hand
+//
+// Many saves are prompted by the alarm itself, so the typical interval is used
+// as it is: waiting any longer than it would push every later interval up too.
+// The result is kept between minInterval and maxInterval. Without enough
+// history, base is returned unchanged.
+func adaptiveInterval(recent, session []time.Duration, base, minInterval, maxInterval time.Duration) time.Duration {
+	if len(recent) < adaptiveMinSamples {
+		return base
+	}
+	typical := medianDuration(recent)
+	interval := typical
+	if n := len(session); n > 0 && session[n-1] > 2*typical {
+		interval = interval * 3 / 4
+	} else if n >= 3 && session[n-1] < typical/2 && session[n-2] < typical/2 && session[n-3] < typical/2 {
+		interval = interval * 5 / 4
+	}
+
+	if interval < minInterval {
+		interval = minInterval
+	}
+	if interval > maxInterval {
+		interval = maxInterval
+	}
+	return interval.Round(time.Second)
+}
+
+// medianDuration returns the median of a list of durations
+func medianDuration(durations []time.Duration) time.Duration {
+	if len(durations) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
+
+// adaptiveBounds parses the adaptive interval limits from the config
+func (sr *SaveReminder) adaptiveBounds() (minInterval, maxInterval time.Duration) {
+	minInterval, err := time.ParseDuration(sr.config.AdaptiveMinInterval)
+	if err != nil || minInterval <= 0 {
+		slog.Warn("Invalid adaptive_min_interval in config, using 3m", "error", err)
+		minInterval = 3 * time.Minute
+	}
+	maxInterval, err = time.ParseDuration(sr.config.AdaptiveMaxInterval)
+	if err != nil || maxInterval <= 0 {
+		slog.Warn("Invalid adaptive_max_interval in config, using 15m", "error", err)
+		maxInterval = 15 * time.Minute
+	}
+	if maxInterval < minInterval {
+		maxInterval = minInterval
+	}
+	return minInterval, maxInterval
+}
+
+// loadSaveIntervals seeds the adaptive interval with the save intervals in the history journal
+func (sr *SaveReminder) loadSaveIntervals(historyPath string) {
+	events, err := readHistory(historyPath)
+	if err != nil {
+		// A missing journal just means there's nothing to learn from yet
+		return
+	}
+	intervals := computeStats(events).SaveIntervals
+	if len(intervals) > adaptiveSamples {
+		intervals = intervals[len(intervals)-adaptiveSamples:]
+	}
+
+	sr.mu.Lock()
+	sr.saveIntervals = intervals
+	sr.mu.Unlock()
+	sr.updateAdaptiveInterval()
+}
+
+// recordSaveInterval remembers the time between two saves in the same session
+func (sr *SaveReminder) recordSaveInterval(interval time.Duration) {
+	sr.mu.Lock()
+	sr.saveIntervals = append(sr.saveIntervals, interval)
+	if len(sr.saveIntervals) > adaptiveSamples {
+		sr.saveIntervals = sr.saveIntervals[len(sr.saveIntervals)-adaptiveSamples:]
+	}
+	sr.sessionIntervals = append(sr.sessionIntervals, interval)
+	sr.mu.Unlock()
+	sr.updateAdaptiveInterval()
+}
+
+// updateAdaptiveInterval recalculates the adaptive alarm interval, if adaptive mode is on
+func (sr *SaveReminder) updateAdaptiveInterval() {
+	if !sr.config.AdaptiveInterval {
+		return
+	}
+	base := sr.configuredAlarmInterval()
+	minInterval, maxInterval := sr.adaptiveBounds()
+
+	sr.mu.Lock()
+	interval := adaptiveInterval(sr.saveIntervals, sr.sessionIntervals, base, minInterval, maxInterval)
+	changed := interval != sr.adaptiveDelay
+	sr.adaptiveDelay = interval
+	samples := len(sr.saveIntervals)
+	typical := medianDuration(sr.saveIntervals)
+	sr.mu.Unlock()
+
+	if !changed {
+		return
+	}
+	if samples < adaptiveMinSamples {
+		slog.Info("Adaptive alarm interval: not enough save history yet, using alarm_interval", "interval", interval, "samples", samples)
+		return
+	}
+	slog.Info("Adaptive alarm interval updated", "interval", interval, "typical_save_interval", typical.Round(time.Second), "samples", samples)
+}
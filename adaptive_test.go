@@ -0,0 +1,71 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMedianDuration(t *testing.T) {
+	tests := []struct {
+		durations []time.Duration
+		want      time.Duration
+	}{
+		{nil, 0},
+		{[]time.Duration{3 * time.Minute}, 3 * time.Minute},
+		{[]time.Duration{9 * time.Minute, time.Minute, 5 * time.Minute}, 5 * time.Minute},
+		{[]time.Duration{8 * time.Minute, 2 * time.Minute, 4 * time.Minute, 6 * time.Minute}, 5 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := medianDuration(tt.durations); got != tt.want {
+			t.Errorf("medianDuration(%v) = %v, want %v", tt.durations, got, tt.want)
+		}
+	}
+}
+
+func TestAdaptiveInterval(t *testing.T) {
+	const m = time.Minute
+	minutes := func(values ...int) []time.Duration {
+		durations := make([]time.Duration, len(values))
+		for i, v := range values {
+			durations[i] = time.Duration(v) * m
+		}
+		return durations
+	}
+	typical := minutes(8, 8, 8, 8, 8)
+	tests := []struct {
+		name    string
+		recent  []time.Duration
+		session []time.Duration
+		base    time.Duration
+		want    time.Duration
+	}{
+		{"not enough history uses alarm_interval unclamped", minutes(8, 8), nil, 20 * m, 20 * m},
+		{"typical interval", typical, nil, 5 * m, 8 * m},
+		{"median ignores outliers", minutes(7, 8, 9, 60, 8), nil, 5 * m, 8 * m},
+		{"tighter after a long gap", typical, minutes(17), 5 * m, 6 * m},
+		{"looser after rapid saves", typical, minutes(3, 3, 3), 5 * m, 10 * m},
+		{"two rapid saves aren't enough", typical, minutes(3, 3), 5 * m, 8 * m},
+		{"clamped to the minimum", minutes(1, 1, 1, 1, 1), nil, 5 * m, 3 * m},
+		{"clamped to the maximum", minutes(30, 30, 30, 30, 30), nil, 5 * m, 15 * m},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := adaptiveInterval(tt.recent, tt.session, tt.base, 3*m, 15*m); got != tt.want {
+				t.Errorf("adaptiveInterval = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAdaptiveIntervalDoesNotRatchet(t *testing.T) {
+	// A player who always saves as soon as the alarm sounds must not see the
+	// interval creep up to the maximum
+	recent := []time.Duration{8 * time.Minute, 8 * time.Minute, 8 * time.Minute, 8 * time.Minute, 8 * time.Minute}
+	for i := 0; i < 50; i++ {
+		interval := adaptiveInterval(recent, nil, 5*time.Minute, 3*time.Minute, 15*time.Minute)
+		if interval != 8*time.Minute {
+			t.Fatalf("after %d alarm-prompted saves the interval is %v, want it to stay at 8m", i, interval)
+		}
+		recent = append(recent, interval)
+	}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// archiveSuffix is appended to a backup's name to get its archive's name
+const archiveSuffix = ".zip"
+
+// archiveName returns the file name of a backup's archive
+func archiveName(backup string, cipher *backupCipher) string {
+	if cipher != nil {
+		return backup + archiveSuffix + encryptedSuffix
+	}
+	return backup + archiveSuffix
+}
+
+// parseArchiveName returns the backup an archive file name belongs to and
+// whether the archive is encrypted
+func parseArchiveName(name string) (backup string, encrypted, ok bool) {
+	if strings.HasSuffix(name, archiveSuffix+encryptedSuffix) {
+		name, encrypted = strings.TrimSuffix(name, encryptedSuffix), true
+	}
+	if !strings.HasSuffix(name, archiveSuffix) {
+		return "", false, false
+	}
+	backup = strings.TrimSuffix(name, archiveSuffix)
+	if _, _, ok := parseBackupName(backup); !ok {
+		return "", false, false
+	}
+	return backup, encrypted, true
+}
+
+// packBackup archives a backup folder, encrypting the archive if a cipher is given
+func packBackup(dir string, cipher *backupCipher) ([]byte, error) {
+	archive, err := zipDirectory(dir)
+	if err != nil || cipher == nil {
+		return archive, err
+	}
+	return cipher.encrypt(archive)
+}
+
+// unpackBackup extracts an archive made by packBackup into a new folder
+func unpackBackup(archive []byte, name string, cipher *backupCipher, dir string) error {
+	if _, encrypted, _ := parseArchiveName(name); encrypted {
+		if cipher == nil {
+			return fmt.Errorf("%s is encrypted, but no passphrase or key file is configured", name)
+		}
+		plain, err := cipher.decrypt(archive)
+		if err != nil {
+			return err
+		}
+		archive = plain
+	}
+	return unzipDirectory(archive, dir)
+}
+
+// sidecarName returns the name of the file kept next to a backup's archive
+// that holds its metadata; it is encrypted if the archive is
+func sidecarName(backup string, encrypted bool) string {
+	if encrypted {
+		return backup + metadataSidecarSuffix + encryptedSuffix
+	}
+	return backup + metadataSidecarSuffix
+}
+
+// packSidecar encodes a backup's metadata for its sidecar file, encrypting it if a cipher is given
+func packSidecar(meta backupMetadata, cipher *backupCipher) ([]byte, error) {
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("error encoding backup metadata: %v", err)
+	}
+	if cipher == nil {
+		return data, nil
+	}
+	return cipher.encrypt(data)
+}
+
+// unpackSidecar decodes a sidecar file made by packSidecar
+func unpackSidecar(data []byte, name string, cipher *backupCipher) (backupMetadata, error) {
+	var meta backupMetadata
+	if strings.HasSuffix(name, encryptedSuffix) {
+		if cipher == nil {
+			return meta, fmt.Errorf("%s is encrypted, but no passphrase or key file is configured", name)
+		}
+		plain, err := cipher.decrypt(data)
+		if err != nil {
+			return meta, err
+		}
+		data = plain
+	}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return meta, fmt.Errorf("invalid metadata in %s: %v", name, err)
+	}
+	return meta, nil
+}
+
+// zipDirectory packs a folder into a zip archive in memory. Save folders are a
+// few megabytes at most; full snapshots are never archived (see takesBackup).
+func zipDirectory(dir string) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		header, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+		header.Method = zip.Deflate
+		w, err := zw.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	})
+	if err == nil {
+		err = zw.Close()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error archiving %s: %v", dir, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// unzipDirectory extracts a zip archive into a new folder
+func unzipDirectory(archive []byte, dir string) error {
+	zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		return fmt.Errorf("invalid archive: %v", err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("error creating folder: %v", err)
+	}
+	for _, f := range zr.File {
+		name := strings.TrimSuffix(f.Name, "/")
+		// Zip names always use "/". A backslash or colon would be a separator or
+		// volume on Windows, and ".." anywhere could lead out of dir.
+		if strings.ContainsAny(name, `\:`) || !filepath.IsLocal(filepath.FromSlash(name)) {
+			return fmt.Errorf("invalid file name in archive: %s", f.Name)
+		}
+		if strings.HasSuffix(f.Name, "/") {
+			continue
+		}
+		dst := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return fmt.Errorf("error creating folder: %v", err)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return fmt.Errorf("error reading %s from archive: %v", f.Name, err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return fmt.Errorf("error reading %s from archive: %v", f.Name, err)
+		}
+		if err := os.WriteFile(dst, data, 0644); err != nil {
+			return fmt.Errorf("error writing %s: %v", dst, err)
+		}
+		os.Chtimes(dst, f.Modified, f.Modified)
+	}
+	return nil
+}
+
+// hashDirectory returns the SHA-256 of every file in a folder, by path
+// relative to the folder
+func hashDirectory(dir string) (map[string]string, error) {
+	hashes := make(map[string]string)
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(data)
+		hashes[filepath.ToSlash(rel)] = hex.EncodeToString(sum[:])
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %v", dir, err)
+	}
+	return hashes, nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// zipWith builds an archive holding one small file per name
+func zipWith(t *testing.T, names ...string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range names {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte("data"))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestUnzipDirectoryRejectsEscapingNames(t *testing.T) {
+	for _, name := range []string{
+		"../x",
+		"a/../../x",
+		"/etc/x",
+		`..\..\x`,
+		`a\..\..\x`,
+		"C:/x",
+		"C:x",
+		`\\server\share\x`,
+		"..",
+	} {
+		t.Run(name, func(t *testing.T) {
+			root := t.TempDir()
+			dir := filepath.Join(root, "restore")
+			if err := unzipDirectory(zipWith(t, name), dir); err == nil {
+				t.Fatalf("archive entry %q was accepted", name)
+			}
+			entries, _ := os.ReadDir(root)
+			if len(entries) > 1 {
+				t.Fatalf("archive entry %q wrote outside the restore folder: %v", name, entries)
+			}
+		})
+	}
+}
+
+func TestUnzipDirectoryRoundTrip(t *testing.T) {
+	src := t.TempDir()
+	for name, data := range map[string]string{
+		"quicksave.sav":     "save",
+		"sub/folder/player": "bic",
+	} {
+		path := filepath.Join(src, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	archive, err := zipDirectory(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst := filepath.Join(t.TempDir(), "restore")
+	if err := unzipDirectory(archive, dst); err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := hashDirectory(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := hashDirectory(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("restored %d files, want %d", len(got), len(want))
+	}
+	for name, hash := range want {
+		if got[name] != hash {
+			t.Errorf("%s differs after the round trip", name)
+		}
+	}
+}
+
+func TestUnzipDirectoryAllowsInnerDotDot(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "restore")
+	if err := unzipDirectory(zipWith(t, "a/../b.txt", "c/d.txt"), dir); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"b.txt", "c/d.txt"} {
+		if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(name))); err != nil {
+			t.Errorf("%s was not extracted: %v", name, err)
+		}
+	}
+}
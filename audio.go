@@ -0,0 +1,459 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gopxl/beep"
+	"github.com/gopxl/beep/effects"
+	"github.com/gopxl/beep/flac"
+	"github.com/gopxl/beep/mp3"
+	"github.com/gopxl/beep/speaker"
+	"github.com/gopxl/beep/vorbis"
+	"github.com/gopxl/beep/wav"
+)
+
+// mixerSampleRate is the single output rate; every sound is resampled to it
+const mixerSampleRate beep.SampleRate = 44100
+
+// mixerFormat is the format decoded sounds are cached in
+var mixerFormat = beep.Format{SampleRate: mixerSampleRate, NumChannels: 2, Precision: 2}
+
+// Loudness normalization targets
+const (
+	normalizeTargetRMS = 0.1  // -20 dBFS average level for normalized sounds
+	normalizePeakLimit = 0.98 // Never boost a sound's peak past this (about -0.2 dBFS)
+	normalizeMaxGain   = 10.0 // At most +20 dB, so near-silent files aren't blown up
+	loudnessBlock      = 100 * time.Millisecond
+	loudnessGate       = 0.001 // Blocks quieter than -60 dBFS (silence) are ignored when measuring
+	decibelFloor       = -120  // Level reported for silence, as the JSON log can't hold -Inf
+)
+
+// sound is decoded audio at the mixer sample rate, with its loudness measured once
+type sound struct {
+	buffer *beep.Buffer
+	rms    float64 // Average level of the non-silent parts (linear, 1 = full scale)
+	peak   float64 // Highest absolute sample value
+}
+
+// cachedSound is a decoded sound file held in memory
+type cachedSound struct {
+	*sound
+	modTime time.Time
+	size    int64
+}
+
+// audioOutput mixes streamers at mixerSampleRate onto an output device
+type audioOutput interface {
+	Play(s beep.Streamer)
+}
+
+// speakerOutput is the system default output, through the beep speaker
+type speakerOutput struct{}
+
+func (speakerOutput) Play(s beep.Streamer) {
+	speaker.Play(s)
+}
+
+// openSpeaker initializes the beep speaker on the system default output
+func openSpeaker() (audioOutput, error) {
+	if err := speaker.Init(mixerSampleRate, mixerSampleRate.N(time.Second/10)); err != nil {
+		return nil, err
+	}
+	return speakerOutput{}, nil
+}
+
+// audioPlayer owns the output device and a cache of decoded alarm sounds.
+// The output is opened once at mixerSampleRate and shared by all sounds,
+// so files with different sample rates all play at the right speed.
+type audioPlayer struct {
+	mu          sync.Mutex
+	device      string // Output device from the config ("" = system default, "none" = no audio)
+	normalize   bool   // Apply loudness normalization to every sound
+	initialized bool
+	initErr     error       // Set once initialization has failed; audio stays unavailable
+	output      audioOutput // Set once initialized
+	cache       map[string]*cachedSound
+}
+
+// newAudioPlayer creates an audio player. The output device is opened lazily on first use.
+func newAudioPlayer(device string, normalize bool) *audioPlayer {
+	return &audioPlayer{
+		device:    strings.TrimSpace(device),
+		normalize: normalize,
+		cache:     make(map[string]*cachedSound),
+	}
+}
+
+// ensureSpeaker opens the output device if it isn't open yet. ap.mu must be held.
+func (ap *audioPlayer) ensureSpeaker() error {
+	if ap.initialized {
+		return nil
+	}
+	if ap.initErr != nil {
+		return ap.initErr
+	}
+
+	var output audioOutput
+	var err error
+	switch {
+	case strings.EqualFold(ap.device, "none"):
+		ap.initErr = fmt.Errorf("audio output disabled (audio_device is \"none\")")
+		return ap.initErr
+	case ap.choosesDevice():
+		output, err = openAudioDevice(ap.device)
+	default:
+		output, err = openSpeaker()
+	}
+	if err != nil {
+		ap.initErr = fmt.Errorf("no usable audio output device: %v", err)
+		return ap.initErr
+	}
+	ap.output = output
+	ap.initialized = true
+	slog.Debug("Speaker initialized", "sample_rate", int(mixerSampleRate))
+	return nil
+}
+
+// choosesDevice reports whether audio_device names a device rather than
+// leaving the choice to the system
+func (ap *audioPlayer) choosesDevice() bool {
+	switch strings.ToLower(ap.device) {
+	case "", "default", "none":
+		return false
+	}
+	return true
+}
+
+// matchAudioDevice picks the device audio_device names out of the devices an
+// output API lists: by its number, else the one whose name contains it
+// (ignoring case). An exact name wins over a partial one.
+func matchAudioDevice(device string, names []string) (int, error) {
+	if id, err := strconv.Atoi(device); err == nil {
+		if id < 0 || id >= len(names) {
+			return 0, fmt.Errorf("audio device %d not found (%d devices)", id, len(names))
+		}
+		return id, nil
+	}
+	match := -1
+	for id, name := range names {
+		if strings.EqualFold(name, device) {
+			return id, nil
+		}
+		if match < 0 && strings.Contains(strings.ToLower(name), strings.ToLower(device)) {
+			match = id
+		}
+	}
+	if match < 0 {
+		return 0, fmt.Errorf("no audio device matches %q (devices: %s)", device, strings.Join(names, ", "))
+	}
+	return match, nil
+}
+
+// available reports whether sounds can be played, opening the output device if needed
+func (ap *audioPlayer) available() error {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+	return ap.ensureSpeaker()
+}
+
+// load returns the decoded sound for a file, decoding it on first use.
+// The cache is refreshed if the file changes on disk.
+func (ap *audioPlayer) load(filePath string) (*sound, error) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("audio file not accessible: %v", err)
+	}
+
+	ap.mu.Lock()
+	cached, ok := ap.cache[filePath]
+	ap.mu.Unlock()
+	if ok && cached.modTime.Equal(info.ModTime()) && cached.size == info.Size() {
+		return cached.sound, nil
+	}
+
+	buffer, err := decodeSound(filePath)
+	if err != nil {
+		return nil, err
+	}
+	snd := newSound(buffer)
+
+	ap.mu.Lock()
+	ap.cache[filePath] = &cachedSound{sound: snd, modTime: info.ModTime(), size: info.Size()}
+	ap.mu.Unlock()
+	slog.Debug("Decoded and cached sound", "file", filepath.Base(filePath),
+		"length", mixerSampleRate.D(buffer.Len()), "level_dbfs", toDecibels(snd.rms), "peak_dbfs", toDecibels(snd.peak))
+	return snd, nil
+}
+
+// decodeSound decodes a whole audio file into a buffer at the mixer sample rate
+func decodeSound(filePath string) (*beep.Buffer, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("error opening audio file: %v", err)
+	}
+	// The decoders close the file via streamer.Close, but not on every error path
+	defer f.Close()
+
+	var streamer beep.StreamSeekCloser
+	var format beep.Format
+	ext := strings.ToLower(filepath.Ext(filePath))
+	switch ext {
+	case ".wav":
+		streamer, format, err = wav.Decode(f)
+	case ".mp3":
+		streamer, format, err = mp3.Decode(f)
+	case ".flac":
+		streamer, format, err = flac.Decode(f)
+	case ".ogg", ".oga":
+		streamer, format, err = vorbis.Decode(f)
+	default:
+		return nil, fmt.Errorf("unsupported audio format '%s'. Supported formats: WAV, MP3, FLAC, OGG Vorbis", ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error decoding audio file: %v", err)
+	}
+	defer streamer.Close()
+
+	var source beep.Streamer = streamer
+	if format.SampleRate != mixerSampleRate {
+		source = beep.Resample(4, format.SampleRate, mixerSampleRate, streamer)
+	}
+
+	buffer := beep.NewBuffer(mixerFormat)
+	buffer.Append(source)
+	if buffer.Len() == 0 {
+		return nil, fmt.Errorf("audio file contains no audio: %s", filePath)
+	}
+	return buffer, nil
+}
+
+// playback is an alarm sound or spoken reminder that is playing. It can be
+// stopped at any time; Done is closed once it has finished or been stopped.
+type playback struct {
+	stopped  atomic.Bool
+	stopOnce sync.Once
+	stop     chan struct{} // Closed by Stop, for speech engines to watch
+	done     chan struct{}
+}
+
+// newPlayback creates a playback that is still running
+func newPlayback() *playback {
+	return &playback{stop: make(chan struct{}), done: make(chan struct{})}
+}
+
+// Stop ends the playback: a sound within one speaker buffer (about 100ms),
+// speech by ending the speech engine
+func (p *playback) Stop() {
+	p.stopOnce.Do(func() {
+		p.stopped.Store(true)
+		close(p.stop)
+	})
+}
+
+// Done returns a channel that is closed when playback ends
+func (p *playback) Done() <-chan struct{} {
+	return p.done
+}
+
+// Stopped reports whether Stop was called
+func (p *playback) Stopped() bool {
+	return p.stopped.Load()
+}
+
+// stoppableStreamer ends its stream as soon as the playback is stopped
+type stoppableStreamer struct {
+	beep.Streamer
+	p *playback
+}
+
+func (s *stoppableStreamer) Stream(samples [][2]float64) (int, bool) {
+	if s.p.Stopped() {
+		return 0, false
+	}
+	return s.Streamer.Stream(samples)
+}
+
+// fadeInStreamer raises the level from silence to full over the first samples.
+// The gain follows a squared curve, which sounds like a steady rise in loudness.
+type fadeInStreamer struct {
+	beep.Streamer
+	pos    int
+	length int
+}
+
+func (f *fadeInStreamer) Stream(samples [][2]float64) (int, bool) {
+	n, ok := f.Streamer.Stream(samples)
+	for i := 0; i < n && f.pos < f.length; i++ {
+		g := float64(f.pos) / float64(f.length)
+		samples[i][0] *= g * g
+		samples[i][1] *= g * g
+		f.pos++
+	}
+	return n, ok
+}
+
+// playbackOptions control how an alarm sound is played
+type playbackOptions struct {
+	fadeIn      time.Duration // Fade in from silence over this long (0 = start at full level)
+	loop        bool          // Repeat the sound until stopped or maxDuration is reached
+	maxDuration time.Duration // Cut playback off after this long (0 = no limit)
+}
+
+// start plays a streamer on the shared output without waiting for it to finish
+func (ap *audioPlayer) start(s beep.Streamer) (*playback, error) {
+	ap.mu.Lock()
+	err := ap.ensureSpeaker()
+	output := ap.output
+	ap.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	p := newPlayback()
+	output.Play(beep.Seq(&stoppableStreamer{Streamer: s, p: p}, beep.Callback(func() {
+		close(p.done)
+	})))
+	return p, nil
+}
+
+// startSound plays a sound at a volume level (0-100) without waiting for it to finish
+func (ap *audioPlayer) startSound(snd *sound, volumeLevel int, opts playbackOptions) (*playback, error) {
+	var s beep.Streamer = snd.buffer.Streamer(0, snd.buffer.Len())
+	if opts.loop {
+		s = beep.Loop(-1, snd.buffer.Streamer(0, snd.buffer.Len()))
+	}
+	if opts.maxDuration > 0 {
+		s = beep.Take(mixerSampleRate.N(opts.maxDuration), s)
+	}
+	if opts.fadeIn > 0 {
+		s = &fadeInStreamer{Streamer: s, length: mixerSampleRate.N(opts.fadeIn)}
+	}
+	return ap.start(ap.volume(s, snd, volumeLevel))
+}
+
+// playStreamer plays a streamer on the shared output and blocks until it
+// finishes, or until stop is closed
+func (ap *audioPlayer) playStreamer(s beep.Streamer, stop <-chan struct{}) error {
+	p, err := ap.start(s)
+	if err != nil {
+		return err
+	}
+	select {
+	case <-p.Done():
+	case <-stop:
+		p.Stop()
+		<-p.Done()
+	}
+	return nil
+}
+
+// newSound measures the loudness of decoded audio.
+// The level is the RMS of 100ms blocks louder than -60 dBFS, so leading or
+// trailing silence doesn't make a short chime look quieter than it sounds.
+func newSound(buffer *beep.Buffer) *sound {
+	snd := &sound{buffer: buffer}
+
+	blockSize := mixerSampleRate.N(loudnessBlock)
+	samples := make([][2]float64, blockSize)
+	streamer := buffer.Streamer(0, buffer.Len())
+	var gatedSum float64
+	var gatedCount int
+	for {
+		n, ok := streamer.Stream(samples)
+		if n > 0 {
+			var blockSum float64
+			for _, sample := range samples[:n] {
+				for _, v := range sample {
+					blockSum += v * v
+					if a := math.Abs(v); a > snd.peak {
+						snd.peak = a
+					}
+				}
+			}
+			if math.Sqrt(blockSum/float64(2*n)) >= loudnessGate {
+				gatedSum += blockSum
+				gatedCount += 2 * n
+			}
+		}
+		if !ok {
+			break
+		}
+	}
+	if gatedCount > 0 {
+		snd.rms = math.Sqrt(gatedSum / float64(gatedCount))
+	}
+	return snd
+}
+
+// normalizationGain returns the gain that brings the sound to the target level
+// without pushing its peak into clipping
+func (snd *sound) normalizationGain() float64 {
+	if snd.rms <= 0 || snd.peak <= 0 {
+		return 1
+	}
+	gain := normalizeTargetRMS / snd.rms
+	if limit := normalizePeakLimit / snd.peak; gain > limit {
+		gain = limit
+	}
+	if gain > normalizeMaxGain {
+		gain = normalizeMaxGain
+	}
+	return gain
+}
+
+// streamer returns a playable streamer for the sound at a volume level (0-100),
+// normalized to the target loudness if enabled
+func (ap *audioPlayer) streamer(snd *sound, volumeLevel int) beep.Streamer {
+	return ap.volume(snd.buffer.Streamer(0, snd.buffer.Len()), snd, volumeLevel)
+}
+
+// volume applies a volume level and the sound's normalization gain (if enabled) to s
+func (ap *audioPlayer) volume(s beep.Streamer, snd *sound, volumeLevel int) beep.Streamer {
+	gain := 1.0
+	if ap.normalize {
+		gain = snd.normalizationGain()
+	}
+	return volumeStreamer(s, volumeLevel, gain)
+}
+
+// volumeGain maps a volume level (0-100) to a linear amplitude factor.
+// Perceived loudness roughly doubles for every +10 dB, so amplitude follows
+// (level/100)^(log2(10)/2): 50% sounds half as loud as 100% (-10 dB),
+// 25% a quarter as loud (-20 dB), and so on down to silence at 0.
+func volumeGain(volumeLevel int) float64 {
+	if volumeLevel <= 0 {
+		return 0
+	}
+	if volumeLevel >= 100 {
+		return 1
+	}
+	return math.Pow(float64(volumeLevel)/100, math.Log2(10)/2)
+}
+
+// volumeStreamer applies a volume level (0-100) and an extra linear gain to a streamer
+func volumeStreamer(s beep.Streamer, volumeLevel int, gain float64) beep.Streamer {
+	amplitude := volumeGain(volumeLevel) * gain
+	return &effects.Volume{
+		Streamer: s,
+		Base:     10,
+		Volume:   math.Log10(math.Max(amplitude, 1e-6)),
+		Silent:   amplitude == 0,
+	}
+}
+
+// toDecibels converts a linear level to dBFS, never going below decibelFloor
+func toDecibels(level float64) float64 {
+	if level <= 0 {
+		return decibelFloor
+	}
+	return math.Max(20*math.Log10(level), decibelFloor)
+}
@@ -0,0 +1,54 @@
+//go:build !windows
+
+package main
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// openAudioDevice opens the speaker on the ALSA card named by audio_device.
+// ALSA's default device follows ALSA_CARD (card index or name, see
+// "aplay -l"). It's only set while the device is opened, so programs started
+// later, like the speech engines, keep their own default. Under PulseAudio or
+// PipeWire ALSA's default device is the sound server, which ignores ALSA_CARD.
+func openAudioDevice(device string) (audioOutput, error) {
+	if runtime.GOOS != "linux" {
+		slog.Warn("audio_device only works on Windows and Linux, using the system default output", "device", device, "os", runtime.GOOS)
+		return openSpeaker()
+	}
+	if soundServerRunning() {
+		slog.Warn("audio_device has no effect while PulseAudio or PipeWire is running; choose the output in the desktop's sound settings instead", "device", device)
+	}
+	previous, wasSet := os.LookupEnv("ALSA_CARD")
+	os.Setenv("ALSA_CARD", device)
+	defer func() {
+		if wasSet {
+			os.Setenv("ALSA_CARD", previous)
+		} else {
+			os.Unsetenv("ALSA_CARD")
+		}
+	}()
+	slog.Info("Using audio device", "alsa_card", device)
+	return openSpeaker()
+}
+
+// soundServerRunning reports whether PulseAudio or PipeWire (which also
+// answers PulseAudio clients) is running for this user
+func soundServerRunning() bool {
+	if os.Getenv("PULSE_SERVER") != "" {
+		return true
+	}
+	runtimeDir := os.Getenv("XDG_RUNTIME_DIR")
+	if runtimeDir == "" {
+		return false
+	}
+	for _, socket := range []string{"pulse/native", "pipewire-0"} {
+		if _, err := os.Stat(filepath.Join(runtimeDir, socket)); err == nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,202 @@
+//go:build windows
+
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/gopxl/beep"
+)
+
+var (
+	winmm                    = syscall.NewLazyDLL("winmm.dll")
+	procWaveOutGetNumDevs    = winmm.NewProc("waveOutGetNumDevs")
+	procWaveOutGetDevCaps    = winmm.NewProc("waveOutGetDevCapsW")
+	procWaveOutOpen          = winmm.NewProc("waveOutOpen")
+	procWaveOutClose         = winmm.NewProc("waveOutClose")
+	procWaveOutPrepareHeader = winmm.NewProc("waveOutPrepareHeader")
+	procWaveOutWrite         = winmm.NewProc("waveOutWrite")
+	procCreateEvent          = kernel32.NewProc("CreateEventW")
+	procWaitForSingleObject  = kernel32.NewProc("WaitForSingleObject")
+	procCloseHandle          = kernel32.NewProc("CloseHandle")
+)
+
+const (
+	waveFormatIEEEFloat = 3
+	callbackEvent       = 0x50000
+	whdrInQueue         = 0x10
+	// waveBuffers of waveBufferFrames each are queued on the device, about 200ms in all
+	waveBuffers      = 4
+	waveBufferFrames = 2205
+)
+
+// waveOutCaps mirrors the Win32 WAVEOUTCAPSW structure
+type waveOutCaps struct {
+	mid           uint16
+	pid           uint16
+	driverVersion uint32
+	name          [32]uint16
+	formats       uint32
+	channels      uint16
+	reserved      uint16
+	support       uint32
+}
+
+// waveFormatEx mirrors the Win32 WAVEFORMATEX structure
+type waveFormatEx struct {
+	formatTag      uint16
+	channels       uint16
+	samplesPerSec  uint32
+	avgBytesPerSec uint32
+	blockAlign     uint16
+	bitsPerSample  uint16
+	size           uint16
+}
+
+// waveHdr mirrors the Win32 WAVEHDR structure
+type waveHdr struct {
+	data          uintptr
+	bufferLength  uint32
+	bytesRecorded uint32
+	user          uintptr
+	flags         uint32
+	loops         uint32
+	next          uintptr
+	reserved      uintptr
+}
+
+// waveBuffer is a block of samples queued on the device
+type waveBuffer struct {
+	header  waveHdr
+	samples []float32
+}
+
+// waveOutput plays on a chosen device through the Windows waveOut API. The
+// beep speaker always uses the default device, so it mixes its own sounds
+// the same way and keeps the device fed from a goroutine.
+type waveOutput struct {
+	handle  uintptr
+	event   uintptr // Signaled by the device each time it finishes a buffer
+	buffers [waveBuffers]*waveBuffer
+
+	mu    sync.Mutex
+	mixer beep.Mixer
+}
+
+// openAudioDevice opens the output device named by audio_device: its number,
+// or its name (or part of it) as shown in the Windows sound settings
+func openAudioDevice(device string) (audioOutput, error) {
+	if err := winmm.Load(); err != nil {
+		return nil, fmt.Errorf("winmm.dll is not available: %v", err)
+	}
+	count, _, _ := procWaveOutGetNumDevs.Call()
+	names := make([]string, count)
+	for id := range names {
+		var caps waveOutCaps
+		if ret, _, _ := procWaveOutGetDevCaps.Call(uintptr(id), uintptr(unsafe.Pointer(&caps)), unsafe.Sizeof(caps)); ret == 0 {
+			names[id] = syscall.UTF16ToString(caps.name[:])
+		}
+	}
+	id, err := matchAudioDevice(device, names)
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := openWaveOutput(uint32(id))
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("Using audio device", "device", names[id], "id", id)
+	return output, nil
+}
+
+// openWaveOutput opens a waveOut device for float stereo at mixerSampleRate
+// and starts feeding it silence until something is played
+func openWaveOutput(id uint32) (*waveOutput, error) {
+	// Auto-reset, initially not signaled
+	event, _, err := procCreateEvent.Call(0, 0, 0, 0)
+	if event == 0 {
+		return nil, fmt.Errorf("CreateEvent failed: %v", err)
+	}
+	format := waveFormatEx{
+		formatTag:      waveFormatIEEEFloat,
+		channels:       2,
+		samplesPerSec:  uint32(mixerSampleRate),
+		avgBytesPerSec: uint32(mixerSampleRate) * 8,
+		blockAlign:     8,
+		bitsPerSample:  32,
+	}
+	w := &waveOutput{event: event}
+	if ret, _, _ := procWaveOutOpen.Call(uintptr(unsafe.Pointer(&w.handle)), uintptr(id), uintptr(unsafe.Pointer(&format)), event, 0, callbackEvent); ret != 0 {
+		procCloseHandle.Call(event)
+		return nil, fmt.Errorf("waveOutOpen failed (MMRESULT %d)", ret)
+	}
+	for i := range w.buffers {
+		b := &waveBuffer{samples: make([]float32, 2*waveBufferFrames)}
+		// The buffer stays referenced by w, and Go never moves heap memory
+		b.header.data = uintptr(unsafe.Pointer(&b.samples[0]))
+		b.header.bufferLength = uint32(4 * len(b.samples))
+		if ret, _, _ := procWaveOutPrepareHeader.Call(w.handle, uintptr(unsafe.Pointer(&b.header)), unsafe.Sizeof(b.header)); ret != 0 {
+			procWaveOutClose.Call(w.handle)
+			procCloseHandle.Call(event)
+			return nil, fmt.Errorf("waveOutPrepareHeader failed (MMRESULT %d)", ret)
+		}
+		w.buffers[i] = b
+	}
+	go w.run()
+	return w, nil
+}
+
+func (w *waveOutput) Play(s beep.Streamer) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.mixer.Add(s)
+}
+
+// run refills each buffer the device has finished with and queues it again
+func (w *waveOutput) run() {
+	samples := make([][2]float64, waveBufferFrames)
+	for {
+		for _, b := range w.buffers {
+			if atomic.LoadUint32(&b.header.flags)&whdrInQueue != 0 {
+				continue
+			}
+			w.stream(samples)
+			for i, sample := range samples {
+				b.samples[2*i] = float32(math.Max(-1, math.Min(1, sample[0])))
+				b.samples[2*i+1] = float32(math.Max(-1, math.Min(1, sample[1])))
+			}
+			if ret, _, _ := procWaveOutWrite.Call(w.handle, uintptr(unsafe.Pointer(&b.header)), unsafe.Sizeof(b.header)); ret != 0 {
+				slog.Error("Audio device stopped playing", "error", fmt.Sprintf("waveOutWrite failed (MMRESULT %d)", ret))
+				w.drain(samples)
+				return
+			}
+		}
+		procWaitForSingleObject.Call(w.event, 1000)
+	}
+}
+
+// drain keeps streaming sounds into nothing after the device stopped taking
+// buffers (e.g. it was unplugged), one buffer's length at a time so they run
+// in real time. Playbacks still end, and loops and alarm_max_duration behave,
+// so nobody waits on them forever.
+func (w *waveOutput) drain(samples [][2]float64) {
+	for {
+		w.stream(samples)
+		time.Sleep(mixerSampleRate.D(len(samples)))
+	}
+}
+
+// stream mixes the next samples of everything playing
+func (w *waveOutput) stream(samples [][2]float64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.mixer.Stream(samples)
+}
@@ -0,0 +1,29 @@
+package main
+
+import "testing"
+
+func TestMatchAudioDevice(t *testing.T) {
+	names := []string{"Speakers (Realtek High Definition", "Headphones (Realtek High Definit", "Headphones (USB Audio)"}
+	tests := []struct {
+		device string
+		want   int
+		err    bool
+	}{
+		{"1", 1, false},
+		{"3", 0, true},
+		{"usb", 2, false},
+		{"headphones", 1, false}, // First partial match
+		{"Headphones (USB Audio)", 2, false},
+		{"HDMI", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := matchAudioDevice(tt.device, names)
+		if (err != nil) != tt.err {
+			t.Errorf("matchAudioDevice(%q) error = %v, want error %v", tt.device, err, tt.err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("matchAudioDevice(%q) = %d, want %d", tt.device, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,499 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// backupTimestampFormat prefixes every backup folder name
+	backupTimestampFormat = "2006-01-02_15-04-05"
+	// preRestoreLabel marks the safety copy of the quicksave taken before a restore
+	preRestoreLabel = quicksaveName + " (before restore)"
+	// backupMetadataFile holds a backup's metadata inside its folder
+	backupMetadataFile = "backup-info.json"
+)
+
+// backupMetadata is extra information stored with a backup
+type backupMetadata struct {
+	Pinned bool   `json:"pinned,omitempty"` // Keep this backup; it may not be deleted
+	Note   string `json:"note,omitempty"`   // Free text, e.g. "before the Ammon Jerro fight"
+}
+
+// backupInfo describes one backup folder
+type backupInfo struct {
+	Name  string    // Folder name, e.g. "2024-01-02_20-15-00 - 000000 - quicksave"
+	Path  string    // Full path to the folder
+	Time  time.Time // When the backup was made
+	Label string    // Folder name without the timestamp
+	backupMetadata
+}
+
+// listBackups returns the backups in a folder, newest first.
+// Folders that don't start with a backup timestamp are skipped.
+func listBackups(backupsPath string) ([]backupInfo, error) {
+	entries, err := os.ReadDir(backupsPath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading backups folder: %v", err)
+	}
+
+	var backups []backupInfo
+	for _, entry := range entries {
+		// Backups still being copied in end in partialSuffix
+		if !entry.IsDir() || strings.HasSuffix(entry.Name(), partialSuffix) {
+			continue
+		}
+		t, label, ok := parseBackupName(entry.Name())
+		if !ok {
+			continue
+		}
+		b := backupInfo{
+			Name:  entry.Name(),
+			Path:  filepath.Join(backupsPath, entry.Name()),
+			Time:  t,
+			Label: label,
+		}
+		if b.backupMetadata, err = readBackupMetadata(b.Path); err != nil {
+			slog.Warn("Skipping backup metadata", "error", err)
+		}
+		backups = append(backups, b)
+	}
+	sortBackups(backups)
+	return backups, nil
+}
+
+// sortBackups sorts backups newest first
+func sortBackups(backups []backupInfo) {
+	sort.SliceStable(backups, func(i, j int) bool {
+		return backups[i].Time.After(backups[j].Time)
+	})
+}
+
+// parseBackupName splits a backup folder name into its timestamp and label
+func parseBackupName(name string) (t time.Time, label string, ok bool) {
+	if len(name) < len(backupTimestampFormat) {
+		return time.Time{}, "", false
+	}
+	t, err := time.ParseInLocation(backupTimestampFormat, name[:len(backupTimestampFormat)], time.Local)
+	if err != nil {
+		return time.Time{}, "", false
+	}
+	return t, strings.TrimPrefix(name[len(backupTimestampFormat):], " - "), true
+}
+
+// claimBackupName creates the partial folder for a new backup and returns the
+// backup's name and that folder. Two backups with the same label can be made
+// within a second (two characters with the same name, a checkpoint hotkey
+// pressed twice), so " (2)", " (3)", ... is added if the name is taken.
+func (sr *SaveReminder) claimBackupName(base string) (string, string, error) {
+	if err := os.MkdirAll(sr.backupsPath, 0755); err != nil {
+		return "", "", fmt.Errorf("error creating backup folder: %v", err)
+	}
+	for n := 1; ; n++ {
+		name := base
+		if n > 1 {
+			name = fmt.Sprintf("%s (%d)", base, n)
+		}
+		// The partial folder is claimed first: until a backup is finished one
+		// of its two folders always exists, so concurrent backups can't collide
+		partial := filepath.Join(sr.backupsPath, name+partialSuffix)
+		if err := os.Mkdir(partial, 0755); err != nil {
+			if os.IsExist(err) {
+				continue
+			}
+			return "", "", fmt.Errorf("error creating backup folder: %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(sr.backupsPath, name)); err == nil {
+			os.Remove(partial)
+			continue
+		}
+		return name, partial, nil
+	}
+}
+
+// baseLabel strips the " (2)" suffix claimBackupName adds to a taken name,
+// so the backup counts as the same kind as the one it collided with
+func baseLabel(label string) string {
+	if !strings.HasSuffix(label, ")") {
+		return label
+	}
+	i := strings.LastIndex(label, " (")
+	if i < 0 {
+		return label
+	}
+	if _, err := strconv.Atoi(label[i+2 : len(label)-1]); err != nil {
+		return label
+	}
+	return label[:i]
+}
+
+// latestBackup returns the newest quicksave backup, skipping pre-restore safety copies
+func latestBackup(backupsPath string) (backupInfo, error) {
+	backups, err := listBackups(backupsPath)
+	if err != nil {
+		return backupInfo{}, err
+	}
+	if b, ok := findBackup(backups, "latest"); ok {
+		return b, nil
+	}
+	return backupInfo{}, fmt.Errorf("no backups found in %s", backupsPath)
+}
+
+// findBackup looks a backup up by name, or by its timestamp alone, in a list
+// sorted newest first. "latest" is the newest quicksave backup, skipping
+// pre-restore safety copies and full snapshots.
+func findBackup(backups []backupInfo, name string) (backupInfo, bool) {
+	for _, b := range backups {
+		if b.Name == name || (name == "latest" && isQuicksaveBackup(b) && b.Label != preRestoreLabel) {
+			return b, true
+		}
+	}
+	if len(name) == len(backupTimestampFormat) {
+		for _, b := range backups {
+			if strings.HasPrefix(b.Name, name) {
+				return b, true
+			}
+		}
+	}
+	return backupInfo{}, false
+}
+
+// isQuicksaveBackup reports whether a backup is a copy of the quicksave slot,
+// which can be restored into it
+func isQuicksaveBackup(b backupInfo) bool {
+	return strings.HasPrefix(b.Label, quicksaveName)
+}
+
+// readBackupMetadata reads a backup's metadata; backups without any get the zero value
+func readBackupMetadata(backupPath string) (backupMetadata, error) {
+	var meta backupMetadata
+	data, err := os.ReadFile(filepath.Join(backupPath, backupMetadataFile))
+	if os.IsNotExist(err) {
+		return meta, nil
+	}
+	if err != nil {
+		return meta, fmt.Errorf("error reading backup metadata: %v", err)
+	}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return meta, fmt.Errorf("invalid metadata in %s: %v", backupPath, err)
+	}
+	return meta, nil
+}
+
+// writeBackupMetadata stores a backup's metadata in its folder
+func writeBackupMetadata(backupPath string, meta backupMetadata) error {
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding backup metadata: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(backupPath, backupMetadataFile), data, 0644); err != nil {
+		return fmt.Errorf("error writing backup metadata: %v", err)
+	}
+	return nil
+}
+
+// setBackupPinned pins or unpins a backup
+func setBackupPinned(b backupInfo, pinned bool) error {
+	meta := b.backupMetadata
+	meta.Pinned = pinned
+	if err := writeBackupMetadata(b.Path, meta); err != nil {
+		return err
+	}
+	if pinned {
+		slog.Info("Pinned backup", "backup", b.Name)
+	} else {
+		slog.Info("Unpinned backup", "backup", b.Name)
+	}
+	return nil
+}
+
+// setBackupNote sets or, with an empty note, removes a backup's note
+func setBackupNote(b backupInfo, note string) error {
+	meta := b.backupMetadata
+	meta.Note = strings.TrimSpace(note)
+	if err := writeBackupMetadata(b.Path, meta); err != nil {
+		return err
+	}
+	if meta.Note != "" {
+		slog.Info("Set backup note", "backup", b.Name, "note", meta.Note)
+	} else {
+		slog.Info("Removed backup note", "backup", b.Name)
+	}
+	return nil
+}
+
+// describeBackup is a backup's line in backup lists: time, label, pin and note
+func describeBackup(b backupInfo) string {
+	line := fmt.Sprintf("%s  %s", b.Time.Format("2006-01-02 15:04:05"), b.Label)
+	if b.Pinned {
+		line += "  [pinned]"
+	}
+	if b.Note != "" {
+		line += fmt.Sprintf("  %q", b.Note)
+	}
+	return line
+}
+
+// deleteBackup removes a backup folder. Pinned backups must be unpinned first.
+func (sr *SaveReminder) deleteBackup(b backupInfo) error {
+	if b.Pinned {
+		return fmt.Errorf("backup %s is pinned", b.Name)
+	}
+	size, _ := dirSize(b.Path)
+	if err := os.RemoveAll(b.Path); err != nil {
+		return fmt.Errorf("error deleting backup: %v", err)
+	}
+	sr.backupsSizeChanged(-size)
+	slog.Info("Deleted backup", "backup", b.Name)
+	return nil
+}
+
+// restoreBackup replaces the quicksave folder with a backup. The current
+// quicksave is backed up first, so a restore can always be undone.
+func (sr *SaveReminder) restoreBackup(b backupInfo) error {
+	if !isQuicksaveBackup(b) {
+		return fmt.Errorf("%s is not a quicksave backup; copy what you need from %s by hand", b.Name, b.Path)
+	}
+	quicksaveFolder := filepath.Join(sr.savesPath, quicksaveName)
+
+	debounceDelay, err := time.ParseDuration(sr.config.DebounceDelay)
+	if err != nil {
+		debounceDelay = 3 * time.Second
+	}
+
+	// Writing the quicksave folder would otherwise look like a new save
+	sr.mu.Lock()
+	sr.ignoreEventsUntil = time.Now().Add(time.Hour)
+	if sr.debounceTimer != nil {
+		sr.debounceTimer.Stop()
+	}
+	sr.pendingBackup = time.Time{}
+	sr.mu.Unlock()
+	defer func() {
+		sr.mu.Lock()
+		sr.ignoreEventsUntil = time.Now().Add(debounceDelay)
+		sr.mu.Unlock()
+	}()
+
+	if _, err := os.Stat(quicksaveFolder); err == nil {
+		safetyCopy, err := sr.createLabeledBackup(quicksaveFolder, preRestoreLabel, backupMetadata{})
+		if err != nil {
+			return fmt.Errorf("error backing up current quicksave before restore: %v", err)
+		}
+		slog.Info("Current quicksave backed up", "path", safetyCopy)
+	}
+
+	if err := os.RemoveAll(quicksaveFolder); err != nil {
+		return fmt.Errorf("error removing current quicksave: %v", err)
+	}
+	if err := copyDirectory(b.Path, quicksaveFolder); err != nil {
+		return fmt.Errorf("error copying backup into quicksave folder: %v", err)
+	}
+	// The metadata belongs to the backup, not to the game's save
+	os.Remove(filepath.Join(quicksaveFolder, backupMetadataFile))
+
+	// The folder was recreated, so it needs to be watched again
+	if sr.watcher != nil {
+		if err := sr.watcher.Add(quicksaveFolder); err != nil {
+			slog.Warn("Failed to add quicksave folder to watcher", "error", err)
+		}
+	}
+	slog.Info("Restored backup", "backup", b.Name, "path", quicksaveFolder)
+	return nil
+}
+
+// restoreLatestBackup restores the newest quicksave backup
+func (sr *SaveReminder) restoreLatestBackup() error {
+	b, err := latestBackup(sr.backupsPath)
+	if err != nil {
+		return err
+	}
+	return sr.restoreBackup(b)
+}
+
+// runRestore implements the restore command: it restores a backup into the
+// quicksave slot, like the tray and terminal interface do. Backups that are
+// only in a replication target or the S3 bucket are downloaded (and
+// decrypted) into the backups folder first.
+func runRestore(config Config, args []string) int {
+	flags := flag.NewFlagSet("restore", flag.ContinueOnError)
+	from := flags.String("from", "", `replication target folder or "s3" to restore from (default: the backups folder, then each target)`)
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	name := strings.Join(flags.Args(), " ")
+	if name == "" {
+		fmt.Fprintln(os.Stderr, "Usage: nwn2-save-reminder restore [--from <target>] <backup name|latest>")
+		return 2
+	}
+
+	_, savesPath := getSavesFolder()
+	backupsPath := filepath.Join(savesPath, backupFolderName)
+	b, err := locateBackup(config, backupsPath, name, *from)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	sr := &SaveReminder{savesPath: savesPath, backupsPath: backupsPath, config: config}
+	if err := sr.restoreBackup(b); err != nil {
+		fmt.Fprintf(os.Stderr, "Restore failed: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Restored %s into %s\n", b.Name, filepath.Join(savesPath, quicksaveName))
+	return 0
+}
+
+// locateBackup finds a backup for the restore command, downloading it into
+// the backups folder if it's only kept remotely
+func locateBackup(config Config, backupsPath, name, from string) (backupInfo, error) {
+	if from == "" {
+		if backups, err := listBackups(backupsPath); err == nil {
+			if b, ok := findBackup(backups, name); ok {
+				return b, nil
+			}
+		}
+	}
+
+	sinks, err := replicationSinks(config)
+	if err != nil {
+		return backupInfo{}, err
+	}
+	for _, sink := range sinks {
+		isS3 := strings.HasPrefix(sink.String(), "s3://")
+		if from != "" && !(from == "s3" && isS3) && resolveExePath(from) != sink.String() {
+			continue
+		}
+		backups, err := sink.list(backupsPath)
+		if err != nil {
+			slog.Warn("Could not list backups", "target", sink.String(), "error", err)
+			continue
+		}
+		if b, ok := findBackup(backups, name); ok {
+			fmt.Printf("Downloading %s from %s\n", b.Name, sink)
+			return fetchBackup(sink, b, backupsPath)
+		}
+	}
+	if from != "" {
+		return backupInfo{}, fmt.Errorf("backup %s not found in %s", name, from)
+	}
+	return backupInfo{}, fmt.Errorf("backup %s not found", name)
+}
+
+// editBackup pins, unpins or sets the note of a backup for the pin, unpin and
+// note commands, and returns the backup as it is now
+func editBackup(backupsPath, command, name, text string) (backupInfo, error) {
+	backups, err := listBackups(backupsPath)
+	if err != nil {
+		return backupInfo{}, err
+	}
+	b, ok := findBackup(backups, name)
+	if !ok {
+		return backupInfo{}, fmt.Errorf("backup %s not found", name)
+	}
+
+	switch command {
+	case "pin":
+		// Pinning with a note stores both at once
+		if text != "" {
+			b.Note = strings.TrimSpace(text)
+		}
+		err = setBackupPinned(b, true)
+		b.Pinned = true
+	case "unpin":
+		err = setBackupPinned(b, false)
+		b.Pinned = false
+	case "note":
+		err = setBackupNote(b, text)
+		b.Note = strings.TrimSpace(text)
+	default:
+		return backupInfo{}, fmt.Errorf("unknown backup command %q", command)
+	}
+	if err != nil {
+		return backupInfo{}, err
+	}
+	return b, nil
+}
+
+// formatBackupList lists the pinned backups, then the others, newest first.
+// recent limits how many of the others are shown (negative = all of them).
+func formatBackupList(backups []backupInfo, recent int) string {
+	var pinned, others []string
+	for _, b := range backups {
+		if b.Pinned {
+			pinned = append(pinned, "  "+describeBackup(b))
+		} else {
+			others = append(others, "  "+describeBackup(b))
+		}
+	}
+
+	lines := []string{fmt.Sprintf("Pinned (%d):", len(pinned))}
+	if len(pinned) == 0 {
+		lines = append(lines, "  (none)")
+	}
+	lines = append(lines, pinned...)
+	if recent == 0 {
+		return strings.Join(lines, "\n")
+	}
+	lines = append(lines, fmt.Sprintf("Other backups (%d):", len(others)))
+	if recent > 0 && len(others) > recent {
+		hidden := len(others) - recent
+		others = append(others[:recent], fmt.Sprintf("  ... and %d older", hidden))
+	}
+	return strings.Join(append(lines, others...), "\n")
+}
+
+// runList implements the list command
+func runList(config Config, args []string) int {
+	flags := flag.NewFlagSet("list", flag.ContinueOnError)
+	pinnedOnly := flags.Bool("pinned", false, "only list pinned backups")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	_, savesPath := getSavesFolder()
+	backups, err := listBackups(filepath.Join(savesPath, backupFolderName))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	recent := -1
+	if *pinnedOnly {
+		recent = 0
+	}
+	fmt.Println(formatBackupList(backups, recent))
+	return 0
+}
+
+// runEditBackup implements the pin, unpin and note commands. They change the
+// backup's metadata directly, so they work whether or not the reminder is running.
+func runEditBackup(config Config, command string, args []string) int {
+	if len(args) == 0 || (command == "unpin" && len(args) > 1) {
+		switch command {
+		case "pin":
+			fmt.Fprintln(os.Stderr, "Usage: nwn2-save-reminder pin <backup name|timestamp|latest> [note]")
+		case "unpin":
+			fmt.Fprintln(os.Stderr, "Usage: nwn2-save-reminder unpin <backup name|timestamp|latest>")
+		case "note":
+			fmt.Fprintln(os.Stderr, "Usage: nwn2-save-reminder note <backup name|timestamp|latest> [note] (no note removes it)")
+		}
+		return 2
+	}
+
+	_, savesPath := getSavesFolder()
+	b, err := editBackup(filepath.Join(savesPath, backupFolderName), command, args[0], strings.Join(args[1:], " "))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Println(describeBackup(b))
+	return 0
+}
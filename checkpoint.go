@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// checkpointLabel marks backups made on demand with the checkpoint command
+const checkpointLabel = quicksaveName + " (checkpoint)"
+
+// createCheckpoint backs up the quicksave right away as a pinned checkpoint,
+// whatever backup_mode is set to. It doesn't wait for the game to finish
+// writing and doesn't count as a save, so the alarm carries on as before.
+func (sr *SaveReminder) createCheckpoint(note, source string) (string, error) {
+	quicksaveFolder := filepath.Join(sr.savesPath, quicksaveName)
+	if _, err := os.Stat(quicksaveFolder); err != nil {
+		return "", fmt.Errorf("no quicksave to checkpoint: %v", err)
+	}
+
+	path, err := sr.createLabeledBackup(quicksaveFolder, checkpointLabel, backupMetadata{Pinned: true, Note: strings.TrimSpace(note)})
+	if err != nil {
+		return "", fmt.Errorf("checkpoint failed: %v", err)
+	}
+	name := filepath.Base(path)
+	slog.Info("Checkpoint created", "backup", name, "source", source)
+	sr.recordHistory(historyEvent{Type: historyCheckpoint, Backup: name, Source: source})
+	return fmt.Sprintf("Checkpoint created: %s", name), nil
+}
+
+// registerCheckpointHotkey makes a global hotkey create a checkpoint
+func (sr *SaveReminder) registerCheckpointHotkey(spec string) error {
+	hk, err := parseHotkey(spec)
+	if err != nil {
+		return err
+	}
+	return registerHotkey(hk, func() {
+		if _, err := sr.createCheckpoint("", "hotkey"); err != nil {
+			slog.Error("Checkpoint failed", "error", err)
+		}
+	})
+}
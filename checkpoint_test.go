@@ -0,0 +1,51 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckpointsInTheSameSecond(t *testing.T) {
+	savesPath := t.TempDir()
+	quicksave := filepath.Join(savesPath, quicksaveName)
+	os.MkdirAll(quicksave, 0755)
+	os.WriteFile(filepath.Join(quicksave, "save.dat"), []byte("save"), 0644)
+	sr := &SaveReminder{savesPath: savesPath, backupsPath: filepath.Join(savesPath, backupFolderName)}
+
+	// Both calls run well within a second, so their names would be the same
+	for _, note := range []string{"first", "second"} {
+		if _, err := sr.createCheckpoint(note, "test"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A failed copy must only remove its own folder
+	if _, err := sr.createLabeledBackup(filepath.Join(savesPath, "missing"), checkpointLabel, backupMetadata{Pinned: true}); err == nil {
+		t.Fatal("backing up a missing folder succeeded")
+	}
+
+	backups, err := listBackups(sr.backupsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("got %d backups, want 2 separate checkpoints: %v", len(backups), backups)
+	}
+	notes := map[string]bool{}
+	for _, b := range backups {
+		if !b.Pinned {
+			t.Errorf("%s is not pinned", b.Name)
+		}
+		if baseLabel(b.Label) != checkpointLabel {
+			t.Errorf("%s has label %q, want it counted as a checkpoint", b.Name, b.Label)
+		}
+		notes[b.Note] = true
+	}
+	if !notes["first"] || !notes["second"] {
+		t.Errorf("notes = %v, want both checkpoints kept apart", notes)
+	}
+	entries, _ := os.ReadDir(sr.backupsPath)
+	if len(entries) != 2 {
+		t.Errorf("backups folder holds %d entries, want no leftover partial folder", len(entries))
+	}
+}
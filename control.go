@@ -0,0 +1,374 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// controlListRecent is how many unpinned backups the list command shows
+	controlListRecent = 10
+	// controlTokenFile holds the secret a client must send before any command,
+	// next to the executable
+	controlTokenFile = "control.token"
+	// controlAuthTimeout is how long a new connection has to send the token
+	controlAuthTimeout = 5 * time.Second
+)
+
+// controlHelp lists the commands accepted on the console and the control socket
+const controlHelp = `Commands:
+  s, snooze [duration]  Silence alarms for a while (e.g. "s", "s 15", "snooze 20m")
+  a, ack                Stop alarms until the next save
+  r, resume             Cancel snooze/acknowledge and resume alarms
+  c, checkpoint [note]  Back up the quicksave now as a pinned checkpoint
+  snapshot              Take a full snapshot of the vaults, saves and settings now
+  status                Show the reminder state
+  list                  List pinned and recent backups
+  pin <backup> [note]   Pin a backup ("latest", its timestamp or its quoted name)
+  unpin <backup>        Unpin a backup
+  note <backup> [note]  Set or remove a backup's note
+  h, help               Show this help`
+
+// handleControlCommand runs a snooze/acknowledge command and returns the reply.
+// State changes are logged along with where the command came from; logged
+// reports whether that happened so the console doesn't echo the reply twice.
+func (sr *SaveReminder) handleControlCommand(line, source string) (reply string, logged bool) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return "", false
+	}
+
+	switch strings.ToLower(fields[0]) {
+	case "s", "snooze":
+		duration, err := sr.parseSnoozeDuration(fields[1:])
+		if err != nil {
+			return fmt.Sprintf("error: %v", err), false
+		}
+		reply = sr.snooze(duration)
+		sr.recordHistory(historyEvent{Type: historySnooze, Duration: duration.String(), Source: source})
+	case "a", "ack", "acknowledge":
+		reply = sr.acknowledge()
+		sr.recordHistory(historyEvent{Type: historyAcknowledge, Source: source})
+	case "r", "resume":
+		reply = sr.resume()
+	case "c", "checkpoint":
+		_, note, _ := strings.Cut(strings.TrimSpace(line), " ")
+		var err error
+		reply, err = sr.createCheckpoint(note, source)
+		if err != nil {
+			return fmt.Sprintf("error: %v", err), false
+		}
+		// createCheckpoint logs the checkpoint itself
+		return reply, true
+	case "snapshot":
+		// A full snapshot can take a while, so the reply only says it started
+		go func() {
+			if _, err := sr.takeFullSnapshot(true); err != nil {
+				slog.Error("Full snapshot failed", "error", err)
+			}
+		}()
+		reply = "Full snapshot started"
+	case "status":
+		return sr.statusLine(), false
+	case "list", "backups":
+		backups, err := listBackups(sr.backupsPath)
+		if err != nil {
+			return fmt.Sprintf("error: %v", err), false
+		}
+		return formatBackupList(backups, controlListRecent), false
+	case "pin", "unpin", "note":
+		_, args, _ := strings.Cut(strings.TrimSpace(line), " ")
+		name, text := splitBackupArg(args)
+		if name == "" {
+			return fmt.Sprintf("error: usage: %s <backup> [note]", strings.ToLower(fields[0])), false
+		}
+		b, err := editBackup(sr.backupsPath, strings.ToLower(fields[0]), name, text)
+		if err != nil {
+			return fmt.Sprintf("error: %v", err), false
+		}
+		sr.replicateMetadata(b.Name)
+		// Changing a backup is logged by editBackup itself
+		return describeBackup(b), true
+	case "h", "help", "?":
+		return controlHelp, false
+	default:
+		return fmt.Sprintf("error: unknown command %q (type 'h' for help)", fields[0]), false
+	}
+
+	slog.Info(reply, "source", source)
+	return reply, true
+}
+
+// splitBackupArg splits the backup off the start of a command's arguments.
+// Backup names contain spaces, so a full name must be quoted; "latest" and a
+// bare timestamp needn't be.
+func splitBackupArg(args string) (name, rest string) {
+	args = strings.TrimSpace(args)
+	if strings.HasPrefix(args, `"`) {
+		if end := strings.Index(args[1:], `"`); end >= 0 {
+			return args[1 : end+1], strings.TrimSpace(args[end+2:])
+		}
+	}
+	name, rest, _ = strings.Cut(args, " ")
+	return name, strings.TrimSpace(rest)
+}
+
+// parseSnoozeDuration parses an optional snooze argument.
+// A bare number is taken as minutes; no argument uses snooze_duration from the config.
+func (sr *SaveReminder) parseSnoozeDuration(args []string) (time.Duration, error) {
+	arg := sr.config.SnoozeDuration
+	if len(args) > 0 {
+		arg = args[0]
+	}
+	if minutes, err := strconv.Atoi(arg); err == nil {
+		arg = fmt.Sprintf("%dm", minutes)
+	}
+	duration, err := time.ParseDuration(arg)
+	if err != nil {
+		return 0, fmt.Errorf("invalid snooze duration %q", arg)
+	}
+	if duration <= 0 {
+		return 0, fmt.Errorf("snooze duration must be positive")
+	}
+	return duration, nil
+}
+
+// snooze silences alarms for the given duration. If an alarm is due when the
+// snooze ends it fires immediately, then the escalation schedule continues.
+func (sr *SaveReminder) snooze(duration time.Duration) string {
+	sr.mu.Lock()
+	sr.snoozeUntil = time.Now().Add(duration)
+	until := sr.snoozeUntil
+	sr.stopPlaybackLocked()
+	sr.mu.Unlock()
+	sr.saveState()
+
+	return fmt.Sprintf("Alarm snoozed for %v (until %s)", duration, until.Format("15:04:05"))
+}
+
+// acknowledge stops alarms until the next save is detected
+func (sr *SaveReminder) acknowledge() string {
+	sr.mu.Lock()
+	sr.stopAlarmLocked()
+	sr.acknowledged = true
+	sr.snoozeUntil = time.Time{}
+	sr.mu.Unlock()
+	sr.saveState()
+
+	return "Alarm acknowledged, no more alarms until the next save"
+}
+
+// resume cancels any snooze or acknowledge and puts the alarm schedule back in effect
+func (sr *SaveReminder) resume() string {
+	alarmInterval := sr.alarmInterval()
+
+	sr.mu.Lock()
+	wasAcknowledged := sr.acknowledged
+	wasSnoozed := !sr.snoozeUntil.IsZero()
+	sr.acknowledged = false
+	sr.snoozeUntil = time.Time{}
+	if wasAcknowledged && len(sr.paused) == 0 {
+		// The timer was stopped, so work out when the next alarm is due
+		delay := alarmInterval - time.Since(sr.lastSaveTime)
+		if delay < 0 {
+			delay = 0
+		}
+		sr.scheduleAlarmLocked(delay)
+	} else if wasSnoozed && sr.alarmTimer != nil && time.Now().After(sr.lastSaveTime.Add(alarmInterval)) {
+		// Overdue: sound the alarm now rather than waiting for the snooze to end
+		sr.scheduleAlarmLocked(0)
+	}
+	sr.mu.Unlock()
+	sr.saveState()
+
+	return "Alarm resumed"
+}
+
+// statusLine describes the current reminder state
+func (sr *SaveReminder) statusLine() string {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	parts := []string{fmt.Sprintf("last save %v ago", time.Since(sr.lastSaveTime).Round(time.Second))}
+	if len(sr.paused) > 0 {
+		parts = append(parts, fmt.Sprintf("paused (%s)", sr.pauseReasonsLocked()))
+	}
+	switch {
+	case sr.acknowledged:
+		parts = append(parts, "alarm acknowledged until next save")
+	case !sr.snoozeUntil.IsZero() && time.Now().Before(sr.snoozeUntil):
+		parts = append(parts, fmt.Sprintf("snoozed until %s", sr.snoozeUntil.Format("15:04:05")))
+	}
+	if sr.alarmActive && sr.lastAlarmStep > 0 {
+		parts = append(parts, fmt.Sprintf("alarm active (escalation step %d)", sr.lastAlarmStep))
+	} else if sr.alarmActive {
+		parts = append(parts, "alarm active")
+	}
+	if !sr.nextAlarmAt.IsZero() && !sr.acknowledged {
+		parts = append(parts, fmt.Sprintf("next alarm in %v", time.Until(sr.nextAlarmAt).Round(time.Second)))
+	}
+	return "Status: " + strings.Join(parts, ", ")
+}
+
+// readConsoleCommands reads commands typed into the console window
+func (sr *SaveReminder) readConsoleCommands() {
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		if reply, logged := sr.handleControlCommand(scanner.Text(), "console"); reply != "" && !logged {
+			fmt.Println(reply)
+		}
+	}
+}
+
+// loadControlToken returns the control socket token, creating the token file
+// with a new random token, readable only by the current user, if needed
+func loadControlToken() (string, error) {
+	path := resolveExePath(controlTokenFile)
+	if token, err := readControlToken(); err == nil {
+		return token, nil
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("error generating control token: %v", err)
+	}
+	token := hex.EncodeToString(secret)
+	if err := os.WriteFile(path, []byte(token+"\n"), 0600); err != nil {
+		return "", fmt.Errorf("error writing control token: %v", err)
+	}
+	slog.Info("Created control token", "path", path)
+	return token, nil
+}
+
+// readControlToken reads the control socket token written by the running instance
+func readControlToken() (string, error) {
+	data, err := os.ReadFile(resolveExePath(controlTokenFile))
+	if err != nil {
+		return "", err
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("%s is empty", controlTokenFile)
+	}
+	return token, nil
+}
+
+// isHTTPRequest reports whether a line is an HTTP request line, as sent by a
+// web page trying to reach the control socket
+func isHTTPRequest(line string) bool {
+	fields := strings.Fields(line)
+	return len(fields) == 3 && strings.HasPrefix(fields[2], "HTTP/")
+}
+
+// startControlServer listens on a local TCP address for control commands.
+// A client first sends "auth <token>" with the token from controlTokenFile;
+// after that each line received is a command, and the reply is written back
+// as a single line.
+func (sr *SaveReminder) startControlServer(address string) error {
+	token, err := loadControlToken()
+	if err != nil {
+		return err
+	}
+	sr.controlToken = token
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %v", address, err)
+	}
+	sr.controlListener = listener
+	slog.Info("Control socket listening", "address", listener.Addr().String())
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				// Listener closed on shutdown
+				return
+			}
+			go sr.serveControlConn(conn)
+		}
+	}()
+	return nil
+}
+
+func (sr *SaveReminder) serveControlConn(conn net.Conn) {
+	defer conn.Close()
+
+	scanner := bufio.NewScanner(conn)
+	conn.SetReadDeadline(time.Now().Add(controlAuthTimeout))
+	if !scanner.Scan() {
+		return
+	}
+	line := strings.TrimSpace(scanner.Text())
+	if isHTTPRequest(line) {
+		// Most likely a web page; don't answer at all
+		slog.Warn("Rejected HTTP request on the control socket", "remote", conn.RemoteAddr().String())
+		return
+	}
+	token, ok := strings.CutPrefix(line, "auth ")
+	if !ok || subtle.ConstantTimeCompare([]byte(strings.TrimSpace(token)), []byte(sr.controlToken)) != 1 {
+		slog.Warn("Rejected control connection without a valid token", "remote", conn.RemoteAddr().String())
+		fmt.Fprintln(conn, "error: not authorized (send \"auth <token>\" first, the token is in "+controlTokenFile+")")
+		return
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	for scanner.Scan() {
+		reply, _ := sr.handleControlCommand(scanner.Text(), "control socket")
+		if reply == "" {
+			continue
+		}
+		// Keep the protocol line-based: one reply line per command
+		reply = strings.ReplaceAll(reply, "\n", " | ")
+		if _, err := fmt.Fprintln(conn, reply); err != nil {
+			return
+		}
+	}
+}
+
+// runControlClient sends a command-line command to a running instance and prints the reply
+func runControlClient(config Config, args []string) int {
+	if config.ControlAddress == "" {
+		fmt.Fprintln(os.Stderr, "Control socket is disabled (set control_address in config.json)")
+		return 1
+	}
+
+	token, err := readControlToken()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not read the control token (is NWN2 Save Reminder running?): %v\n", err)
+		return 1
+	}
+
+	conn, err := net.DialTimeout("tcp", config.ControlAddress, 3*time.Second)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not reach NWN2 Save Reminder at %s: %v\n", config.ControlAddress, err)
+		return 1
+	}
+	defer conn.Close()
+
+	if _, err := fmt.Fprintf(conn, "auth %s\n%s\n", token, strings.Join(args, " ")); err != nil {
+		fmt.Fprintf(os.Stderr, "Error sending command: %v\n", err)
+		return 1
+	}
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	reply, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading reply: %v\n", err)
+		return 1
+	}
+	reply = strings.TrimSpace(reply)
+	fmt.Println(strings.ReplaceAll(reply, " | ", "\n"))
+	if strings.HasPrefix(reply, "error:") {
+		return 1
+	}
+	return 0
+}
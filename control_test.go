@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServeControlConn(t *testing.T) {
+	tests := []struct {
+		name       string
+		lines      []string
+		want       []string // Prefixes of the reply lines, in order
+		authorized bool     // The connection stays open after the replies
+	}{
+		{"HTTP request gets no answer", []string{"GET /snooze HTTP/1.1"}, nil, false},
+		{"POST from a web page gets no answer", []string{"POST / HTTP/1.0"}, nil, false},
+		{"command without auth", []string{"ack"}, []string{"error: not authorized"}, false},
+		{"wrong token", []string{"auth guess", "ack"}, []string{"error: not authorized"}, false},
+		{"token as a prefix", []string{"auth secret-token-and-more"}, []string{"error: not authorized"}, false},
+		{"valid token", []string{"auth secret-token", "status", "bogus"}, []string{"Status:", "error: unknown command"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sr := &SaveReminder{controlToken: "secret-token", lastSaveTime: time.Now()}
+			client, server := net.Pipe()
+			defer client.Close()
+			go sr.serveControlConn(server)
+			client.SetDeadline(time.Now().Add(5 * time.Second))
+
+			// Write from a goroutine: a rejected connection stops reading
+			go func() {
+				for _, line := range tt.lines {
+					if _, err := io.WriteString(client, line+"\n"); err != nil {
+						return
+					}
+				}
+			}()
+
+			replies := bufio.NewScanner(client)
+			for _, want := range tt.want {
+				if !replies.Scan() {
+					t.Fatalf("connection closed, want a reply starting with %q", want)
+				}
+				if got := replies.Text(); !strings.HasPrefix(got, want) {
+					t.Fatalf("reply %q, want one starting with %q", got, want)
+				}
+			}
+			if tt.authorized {
+				// An authorized connection stays open until the client is done
+				return
+			}
+			if replies.Scan() {
+				t.Errorf("unexpected reply %q, want the connection closed", replies.Text())
+			}
+			if err := replies.Err(); err != nil {
+				t.Errorf("read error %v, want the connection closed", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"filippo.io/age"
+)
+
+// encryptedSuffix is appended to the name of an encrypted archive
+const encryptedSuffix = ".age"
+
+// passphraseEnv can hold the encryption passphrase instead of config.json
+const passphraseEnv = "NWN2_BACKUP_PASSPHRASE"
+
+// EncryptionConfig sets how backups are encrypted before they leave the computer
+type EncryptionConfig struct {
+	Passphrase string `json:"passphrase"` // Passphrase (empty = NWN2_BACKUP_PASSPHRASE, if set)
+	KeyFile    string `json:"key_file"`   // age identity file, relative to the executable (empty = use the passphrase)
+}
+
+// backupCipher encrypts and decrypts backup archives in the age format, so
+// they can also be opened with the standard age tool
+type backupCipher struct {
+	recipient age.Recipient
+	identity  age.Identity
+}
+
+// newBackupCipher creates the cipher from the config, or returns nil if encryption is off
+func newBackupCipher(config EncryptionConfig) (*backupCipher, error) {
+	passphrase := config.Passphrase
+	if passphrase == "" {
+		passphrase = os.Getenv(passphraseEnv)
+	}
+
+	switch {
+	case config.KeyFile != "" && config.Passphrase != "":
+		return nil, fmt.Errorf("set either an encryption passphrase or a key file, not both")
+	case config.KeyFile != "":
+		path := resolveExePath(config.KeyFile)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading key file: %v", err)
+		}
+		identities, err := age.ParseIdentities(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("invalid key file %s: %v", path, err)
+		}
+		x25519, ok := identities[0].(*age.X25519Identity)
+		if !ok {
+			return nil, fmt.Errorf("unsupported key in %s", path)
+		}
+		return &backupCipher{recipient: x25519.Recipient(), identity: x25519}, nil
+	case passphrase != "":
+		recipient, err := age.NewScryptRecipient(passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("invalid passphrase: %v", err)
+		}
+		identity, err := age.NewScryptIdentity(passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("invalid passphrase: %v", err)
+		}
+		return &backupCipher{recipient: recipient, identity: identity}, nil
+	}
+	return nil, nil
+}
+
+// encrypt encrypts data for the configured passphrase or key
+func (c *backupCipher) encrypt(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := age.Encrypt(&buf, c.recipient)
+	if err != nil {
+		return nil, fmt.Errorf("error encrypting: %v", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, fmt.Errorf("error encrypting: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("error encrypting: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// decrypt decrypts data encrypted with the configured passphrase or key
+func (c *backupCipher) decrypt(data []byte) ([]byte, error) {
+	r, err := age.Decrypt(bytes.NewReader(data), c.identity)
+	if err != nil {
+		var noMatch *age.NoIdentityMatchError
+		if errors.As(err, &noMatch) {
+			return nil, fmt.Errorf("wrong passphrase or key file")
+		}
+		return nil, fmt.Errorf("error decrypting: %v", err)
+	}
+	plain, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting: %v", err)
+	}
+	return plain, nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"log/slog"
+	"sort"
+	"time"
+)
+
+// alarmStage is a parsed escalation step with defaults from the main config applied
+type alarmStage struct {
+	index     int           // 1-based position in the escalation schedule (0 = no escalation)
+	after     time.Duration // Time since last save when this stage starts
+	repeat    time.Duration // Time between alarms during this stage
+	soundFile string        // Audio file to play (empty = built-in tone, or the system beep without an audio device)
+	volume    int           // Volume level (0-100)
+}
+
+// buildEscalation parses the escalation schedule from the config.
+// Invalid steps are skipped with a warning; the result is sorted by start time.
+func buildEscalation(config Config) []alarmStage {
+	// loadConfig has already reported an invalid repeat_interval
+	repeatInterval, err := time.ParseDuration(config.RepeatInterval)
+	if err != nil || repeatInterval <= 0 {
+		repeatInterval = 5 * time.Minute
+	}
+
+	var stages []alarmStage
+	for i, step := range config.AlarmEscalation {
+		after, err := time.ParseDuration(step.After)
+		if err != nil {
+			slog.Warn("Invalid 'after' in alarm_escalation, skipping step", "step", i+1, "error", err)
+			continue
+		}
+
+		stage := alarmStage{
+			after:     after,
+			repeat:    repeatInterval,
+			soundFile: config.AlarmSoundFile,
+			volume:    config.AlarmVolume,
+		}
+		if step.Repeat != "" {
+			repeat, err := time.ParseDuration(step.Repeat)
+			if err != nil || repeat <= 0 {
+				slog.Warn("Invalid 'repeat' in alarm_escalation, using repeat_interval", "step", i+1, "repeat", repeatInterval)
+			} else {
+				stage.repeat = repeat
+			}
+		}
+		if step.SoundFile != "" {
+			stage.soundFile = step.SoundFile
+		}
+		if step.Volume != nil {
+			stage.volume = min(max(*step.Volume, 0), 100)
+		}
+		stages = append(stages, stage)
+	}
+
+	sort.SliceStable(stages, func(i, j int) bool {
+		return stages[i].after < stages[j].after
+	})
+	for i := range stages {
+		stages[i].index = i + 1
+	}
+	return stages
+}
+
+// stageFor returns the alarm stage in effect after the given time without saving.
+// Before the first escalation step (or without a schedule) the base config applies.
+func (sr *SaveReminder) stageFor(elapsed time.Duration) alarmStage {
+	// loadConfig has already reported an invalid repeat_interval
+	repeatInterval, err := time.ParseDuration(sr.config.RepeatInterval)
+	if err != nil || repeatInterval <= 0 {
+		repeatInterval = 5 * time.Minute
+	}
+
+	stage := alarmStage{
+		repeat:    repeatInterval,
+		soundFile: sr.config.AlarmSoundFile,
+		volume:    sr.config.AlarmVolume,
+	}
+	for _, s := range sr.escalation {
+		if s.after > elapsed {
+			break
+		}
+		stage = s
+	}
+	return stage
+}
+
+// nextAlarmDelay returns how long to wait before the next alarm.
+// The alarm repeats at the current stage's interval, but fires early
+// if the next escalation step starts before then.
+func (sr *SaveReminder) nextAlarmDelay(elapsed time.Duration) time.Duration {
+	delay := sr.stageFor(elapsed).repeat
+	for _, s := range sr.escalation {
+		if s.after > elapsed {
+			if s.after-elapsed < delay {
+				delay = s.after - elapsed
+			}
+			break
+		}
+	}
+	if delay < time.Second {
+		delay = time.Second
+	}
+	return delay
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestEscalationStages(t *testing.T) {
+	var config Config
+	// Steps out of order, one muted, one invalid
+	err := json.Unmarshal([]byte(`{
+		"repeat_interval": "5m",
+		"alarm_sound_file": "alarm.wav",
+		"alarm_volume": 60,
+		"alarm_escalation": [
+			{"after": "30m", "repeat": "1m", "sound_file": "siren.wav", "volume": 100},
+			{"after": "10m", "repeat": "2m"},
+			{"after": "soon", "volume": 10},
+			{"after": "20m", "volume": 0}
+		]
+	}`), &config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sr := &SaveReminder{config: config, escalation: buildEscalation(config)}
+	if len(sr.escalation) != 3 {
+		t.Fatalf("got %d stages, want 3 (the invalid step skipped)", len(sr.escalation))
+	}
+
+	const m = time.Minute
+	tests := []struct {
+		elapsed   time.Duration
+		index     int
+		repeat    time.Duration
+		soundFile string
+		volume    int
+		nextAlarm time.Duration
+	}{
+		{5 * m, 0, 5 * m, "alarm.wav", 60, 5 * m},
+		{8 * m, 0, 5 * m, "alarm.wav", 60, 2 * m}, // Fires early for the first step
+		{10 * m, 1, 2 * m, "alarm.wav", 60, 2 * m},
+		{20 * m, 2, 5 * m, "alarm.wav", 0, 5 * m},
+		{27 * m, 2, 5 * m, "alarm.wav", 0, 3 * m},
+		{45 * m, 3, 1 * m, "siren.wav", 100, 1 * m},
+	}
+	for _, tt := range tests {
+		stage := sr.stageFor(tt.elapsed)
+		if stage.index != tt.index || stage.repeat != tt.repeat || stage.soundFile != tt.soundFile || stage.volume != tt.volume {
+			t.Errorf("after %v: stage %d (every %v, %s at %d), want stage %d (every %v, %s at %d)", tt.elapsed,
+				stage.index, stage.repeat, stage.soundFile, stage.volume, tt.index, tt.repeat, tt.soundFile, tt.volume)
+		}
+		if got := sr.nextAlarmDelay(tt.elapsed); got != tt.nextAlarm {
+			t.Errorf("after %v: next alarm in %v, want %v", tt.elapsed, got, tt.nextAlarm)
+		}
+	}
+}
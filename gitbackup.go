@@ -0,0 +1,237 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// Values for backup_mode
+const (
+	backupModeFolders = "folders" // A timestamped copy of the quicksave per save
+	backupModeGit     = "git"     // A commit per save in a git repository
+	backupModeBoth    = "both"    // Both of the above
+)
+
+// defaultGitRepository is where git backups go, relative to the backups folder
+const defaultGitRepository = "quicksave.git"
+
+// gitBackup commits quicksaves into a bare git repository. Commits are built
+// directly from the quicksave folder, so there is no working copy to keep in
+// sync, and files that didn't change between saves are stored once.
+type gitBackup struct {
+	mu   sync.Mutex
+	path string
+	repo *git.Repository
+}
+
+// resolveGitRepository returns where the git repository lives; relative paths
+// are taken relative to the backups folder
+func resolveGitRepository(config Config, backupsPath string) string {
+	path := config.GitRepository
+	if path == "" {
+		path = defaultGitRepository
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(backupsPath, path)
+	}
+	return path
+}
+
+// openGitBackup opens the repository, creating it on first use
+func openGitBackup(path string) (*gitBackup, error) {
+	repo, err := git.PlainOpen(path)
+	if err == git.ErrRepositoryNotExists {
+		repo, err = git.PlainInitWithOptions(path, &git.PlainInitOptions{
+			InitOptions: git.InitOptions{DefaultBranch: plumbing.Main},
+			Bare:        true,
+		})
+		if err == nil {
+			slog.Info("Created git backup repository", "path", path)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error opening git repository %s: %v", path, err)
+	}
+	return &gitBackup{path: path, repo: repo}, nil
+}
+
+// commit records the contents of a save folder as a new commit on the current
+// branch and returns its hash. If nothing changed since the last commit, no
+// commit is made and the last commit's hash is returned.
+func (g *gitBackup) commit(folder, label string, when time.Time) (plumbing.Hash, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	tree, err := g.writeTree(folder)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	// HEAD points at the branch even before its first commit
+	head, err := g.repo.Storer.Reference(plumbing.HEAD)
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("error reading HEAD: %v", err)
+	}
+	branch := head.Name()
+	if head.Type() == plumbing.SymbolicReference {
+		branch = head.Target()
+	}
+	var parents []plumbing.Hash
+	if ref, err := g.repo.Storer.Reference(branch); err == nil {
+		parent, err := g.repo.CommitObject(ref.Hash())
+		if err != nil {
+			return plumbing.ZeroHash, fmt.Errorf("error reading last commit: %v", err)
+		}
+		if parent.TreeHash == tree {
+			return parent.Hash, nil
+		}
+		parents = []plumbing.Hash{parent.Hash}
+	} else if err != plumbing.ErrReferenceNotFound {
+		return plumbing.ZeroHash, fmt.Errorf("error reading branch %s: %v", branch, err)
+	}
+
+	signature := object.Signature{Name: "NWN2 Save Reminder", Email: "nwn2-save-reminder@localhost", When: when}
+	commit := &object.Commit{
+		Author:       signature,
+		Committer:    signature,
+		Message:      gitCommitMessage(folder, label, when),
+		TreeHash:     tree,
+		ParentHashes: parents,
+	}
+	obj := g.repo.Storer.NewEncodedObject()
+	if err := commit.Encode(obj); err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("error encoding commit: %v", err)
+	}
+	hash, err := g.repo.Storer.SetEncodedObject(obj)
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("error storing commit: %v", err)
+	}
+	if err := g.repo.Storer.SetReference(plumbing.NewHashReference(branch, hash)); err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("error updating branch %s: %v", branch, err)
+	}
+	return hash, nil
+}
+
+// gitCommitMessage describes a save: the label and time on the first line,
+// then the character and module if the save names them
+func gitCommitMessage(folder, label string, when time.Time) string {
+	message := fmt.Sprintf("%s at %s\n", label, when.Format("2006-01-02 15:04:05"))
+	info := readSaveInfo(folder)
+	if info.Character != "" || info.Module != "" {
+		message += "\n"
+	}
+	if info.Character != "" {
+		message += "Character: " + info.Character + "\n"
+	}
+	if info.Module != "" {
+		message += "Module: " + info.Module + "\n"
+	}
+	return message
+}
+
+// writeTree stores a folder's files as blobs and returns the hash of its tree
+func (g *gitBackup) writeTree(folder string) (plumbing.Hash, error) {
+	entries, err := os.ReadDir(folder)
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("error reading %s: %v", folder, err)
+	}
+
+	var tree object.Tree
+	for _, entry := range entries {
+		path := filepath.Join(folder, entry.Name())
+		switch {
+		case entry.IsDir():
+			hash, err := g.writeTree(path)
+			if err != nil {
+				return plumbing.ZeroHash, err
+			}
+			tree.Entries = append(tree.Entries, object.TreeEntry{Name: entry.Name(), Mode: filemode.Dir, Hash: hash})
+		case entry.Type().IsRegular():
+			hash, err := g.writeBlob(path)
+			if err != nil {
+				return plumbing.ZeroHash, err
+			}
+			tree.Entries = append(tree.Entries, object.TreeEntry{Name: entry.Name(), Mode: filemode.Regular, Hash: hash})
+		}
+	}
+
+	// Git orders entries by name, comparing folders as if they ended in "/"
+	sortKey := func(e object.TreeEntry) string {
+		if e.Mode == filemode.Dir {
+			return e.Name + "/"
+		}
+		return e.Name
+	}
+	sort.Slice(tree.Entries, func(i, j int) bool {
+		return sortKey(tree.Entries[i]) < sortKey(tree.Entries[j])
+	})
+
+	obj := g.repo.Storer.NewEncodedObject()
+	if err := tree.Encode(obj); err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("error encoding tree: %v", err)
+	}
+	hash, err := g.repo.Storer.SetEncodedObject(obj)
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("error storing tree: %v", err)
+	}
+	return hash, nil
+}
+
+// writeBlob stores a file's contents
+func (g *gitBackup) writeBlob(path string) (plumbing.Hash, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("error reading %s: %v", path, err)
+	}
+	defer f.Close()
+
+	obj := g.repo.Storer.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+	w, err := obj.Writer()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if _, err := io.Copy(w, f); err != nil {
+		w.Close()
+		return plumbing.ZeroHash, fmt.Errorf("error reading %s: %v", path, err)
+	}
+	if err := w.Close(); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	hash, err := g.repo.Storer.SetEncodedObject(obj)
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("error storing %s: %v", path, err)
+	}
+	return hash, nil
+}
+
+// backupModeIncludes reports whether backup_mode makes the given kind of backup
+func backupModeIncludes(config Config, mode string) bool {
+	return config.BackupMode == mode || config.BackupMode == backupModeBoth
+}
+
+// lastCommitTime returns when the newest commit on the current branch was made
+func (g *gitBackup) lastCommitTime() (time.Time, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	ref, err := g.repo.Head()
+	if err != nil {
+		return time.Time{}, false
+	}
+	commit, err := g.repo.CommitObject(ref.Hash())
+	if err != nil {
+		return time.Time{}, false
+	}
+	return commit.Committer.When, true
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func TestGitBackupCommit(t *testing.T) {
+	dir := t.TempDir()
+	save := filepath.Join(dir, quicksaveName)
+	os.MkdirAll(filepath.Join(save, "sub"), 0755)
+	os.WriteFile(filepath.Join(save, "save.dat"), []byte("first"), 0644)
+	os.WriteFile(filepath.Join(save, "sub", "module.ifo"), []byte("module"), 0644)
+
+	repoPath := filepath.Join(dir, defaultGitRepository)
+	g, err := openGitBackup(repoPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	when := time.Date(2024, 3, 1, 20, 0, 0, 0, time.Local)
+	first, err := g.commit(save, quicksaveName, when)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The same content again doesn't make a commit
+	again, err := g.commit(save, quicksaveName, when.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != first {
+		t.Errorf("unchanged save committed as %s, want the last commit %s", again, first)
+	}
+
+	os.WriteFile(filepath.Join(save, "save.dat"), []byte("second"), 0644)
+	second, err := g.commit(save, quicksaveName, when.Add(2*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second == first {
+		t.Fatal("changed save was not committed")
+	}
+
+	// Reopen the repository: it is bare and holds two commits, newest first
+	g, err = openGitBackup(repoPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(repoPath, "HEAD")); err != nil {
+		t.Errorf("repository is not bare: %v", err)
+	}
+	log, err := g.repo.Log(&git.LogOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var commits []*object.Commit
+	log.ForEach(func(c *object.Commit) error {
+		commits = append(commits, c)
+		return nil
+	})
+	if len(commits) != 2 || commits[0].Hash != second || commits[1].Hash != first {
+		t.Fatalf("history has %d commits, want %s then %s", len(commits), second, first)
+	}
+	if last, ok := g.lastCommitTime(); !ok || !last.Equal(when.Add(2*time.Minute)) {
+		t.Errorf("lastCommitTime = %v, %v, want %v", last, ok, when.Add(2*time.Minute))
+	}
+
+	// The tree holds the files, including the subfolder
+	for path, want := range map[string]string{"save.dat": "second", "sub/module.ifo": "module"} {
+		file, err := commits[0].File(path)
+		if err != nil {
+			t.Errorf("%s: %v", path, err)
+			continue
+		}
+		if got, _ := file.Contents(); got != want {
+			t.Errorf("%s = %q, want %q", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,456 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultHistoryFile is the history journal used when history_file is not set
+const defaultHistoryFile = "history.jsonl"
+
+// History event types
+const (
+	historySessionStart = "session_start" // Game started (or the app, without game detection)
+	historySessionEnd   = "session_end"   // Game exited or the app stopped
+	historySave         = "save"          // Quicksave detected and backed up
+	historyBackupFailed = "backup_failed" // Quicksave detected but the backup failed
+	historyAlarm        = "alarm"         // Alarm sounded
+	historySnooze       = "snooze"
+	historyAcknowledge  = "acknowledge"
+	historyCheckpoint   = "checkpoint" // Quicksave backed up on demand as a pinned checkpoint
+)
+
+// historyEvent is one line of the history journal
+type historyEvent struct {
+	Time       time.Time `json:"time"`
+	Type       string    `json:"type"`
+	Backup     string    `json:"backup,omitempty"`      // Backup folder name (save, checkpoint)
+	Bytes      int64     `json:"bytes,omitempty"`       // Size of the backup (save)
+	TotalBytes int64     `json:"total_bytes,omitempty"` // Size of all backups after this one (save)
+	Interval   string    `json:"interval,omitempty"`    // Time since the previous save in the same session (save)
+	Elapsed    string    `json:"elapsed,omitempty"`     // Time since the last save (alarm)
+	Step       int       `json:"step,omitempty"`        // Escalation step (alarm)
+	Duration   string    `json:"duration,omitempty"`    // Snooze length (snooze)
+	Source     string    `json:"source,omitempty"`      // Where a command came from (snooze, acknowledge, checkpoint)
+	Error      string    `json:"error,omitempty"`       // What went wrong (backup_failed)
+}
+
+// historyJournal appends events to a JSON lines file
+type historyJournal struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+}
+
+// openHistory opens (or creates) the history journal for appending
+func openHistory(path string) (*historyJournal, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create history folder: %v", err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history file: %v", err)
+	}
+	return &historyJournal{path: path, file: file}, nil
+}
+
+// record appends an event to the journal
+func (h *historyJournal) record(event historyEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("error encoding history event: %v", err)
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.file == nil {
+		return fmt.Errorf("history file is closed")
+	}
+	if _, err := h.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("error writing history: %v", err)
+	}
+	return nil
+}
+
+func (h *historyJournal) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.file == nil {
+		return nil
+	}
+	err := h.file.Close()
+	h.file = nil
+	return err
+}
+
+// resolveHistoryPath returns the journal path from the config, or "" if history is disabled
+func resolveHistoryPath(config Config) string {
+	switch config.HistoryFile {
+	case "none":
+		return ""
+	case "":
+		return resolveExePath(defaultHistoryFile)
+	}
+	return resolveExePath(config.HistoryFile)
+}
+
+// recordHistory adds an event to the history journal, if there is one
+func (sr *SaveReminder) recordHistory(event historyEvent) {
+	if sr.history == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	if err := sr.history.record(event); err != nil {
+		slog.Warn("Could not record history", "error", err)
+	}
+}
+
+// recordSaveHistory records a save along with the size of its backup and of all
+// backups, and the time since the previous save (0 if it was the session's first)
+func (sr *SaveReminder) recordSaveHistory(backupName, backupPath string, interval time.Duration) {
+	if sr.history == nil {
+		return
+	}
+	event := historyEvent{Type: historySave, Backup: backupName}
+	if interval > 0 {
+		event.Interval = interval.Round(time.Second).String()
+	}
+	var err error
+	if backupPath != "" {
+		if event.Bytes, err = dirSize(backupPath); err != nil {
+			slog.Warn("Could not measure backup size", "error", err)
+		}
+	}
+	if event.TotalBytes, err = sr.backupsSize(); err != nil {
+		slog.Warn("Could not measure backups folder size", "error", err)
+	}
+	sr.recordHistory(event)
+}
+
+// backupsSize returns the size of the backups folder. The folder is only
+// walked once per session; after that the running total is used.
+func (sr *SaveReminder) backupsSize() (int64, error) {
+	sr.mu.Lock()
+	total, measured := sr.backupsBytes, sr.backupsMeasured
+	sr.mu.Unlock()
+	if measured {
+		return total, nil
+	}
+	total, err := dirSize(sr.backupsPath)
+	if err != nil {
+		return 0, err
+	}
+	sr.mu.Lock()
+	sr.backupsBytes, sr.backupsMeasured = total, true
+	sr.mu.Unlock()
+	return total, nil
+}
+
+// backupAdded adds a new backup folder to the running total
+func (sr *SaveReminder) backupAdded(path string) {
+	sr.mu.Lock()
+	measured := sr.backupsMeasured
+	sr.mu.Unlock()
+	if !measured {
+		return // The next measurement includes it
+	}
+	size, err := dirSize(path)
+	if err != nil {
+		slog.Debug("Could not measure backup size", "path", path, "error", err)
+		return
+	}
+	sr.backupsSizeChanged(size)
+}
+
+// backupsSizeChanged updates the running total, if the folder has been measured
+func (sr *SaveReminder) backupsSizeChanged(delta int64) {
+	sr.mu.Lock()
+	if sr.backupsMeasured {
+		sr.backupsBytes += delta
+	}
+	sr.mu.Unlock()
+}
+
+// startSession records the start of a play session, unless one is already open
+func (sr *SaveReminder) startSession() {
+	sr.mu.Lock()
+	started := !sr.inSession
+	sr.inSession = true
+	if started {
+		sr.savedThisSession = false
+		sr.sessionIntervals = nil
+		sr.backupsMeasured = false // Picks up changes made outside the reminder
+	}
+	sr.mu.Unlock()
+	if started {
+		sr.recordHistory(historyEvent{Type: historySessionStart})
+	}
+}
+
+// endSession records the end of the current play session, if one is open
+func (sr *SaveReminder) endSession() {
+	sr.mu.Lock()
+	ended := sr.inSession
+	sr.inSession = false
+	sr.mu.Unlock()
+	if ended {
+		sr.recordHistory(historyEvent{Type: historySessionEnd})
+	}
+}
+
+// dirSize returns the total size of the files in a folder
+func dirSize(path string) (int64, error) {
+	var total int64
+	err := filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			total += info.Size()
+		}
+		return nil
+	})
+	return total, err
+}
+
+// readHistory reads all events from a journal, oldest first. Lines that can't
+// be parsed (e.g. cut off by a crash) are skipped.
+func readHistory(path string) ([]historyEvent, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var events []historyEvent
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var event historyEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil || event.Time.IsZero() {
+			continue
+		}
+		events = append(events, event)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading history: %v", err)
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Time.Before(events[j].Time)
+	})
+	return events, nil
+}
+
+// historyStats summarizes the history journal
+type historyStats struct {
+	First, Last    time.Time
+	Sessions       int
+	SessionTime    time.Duration
+	Saves          int
+	FailedBackups  int
+	SaveIntervals  []time.Duration // Time between consecutive saves in the same session
+	LongestUnsaved time.Duration   // Longest time without a save during a session
+	LongestAt      time.Time       // When that streak started
+	Alarms         int
+	Snoozes        int
+	Acknowledges   int
+	BackupUsage    []backupUsage // Disk used by backups at the end of each day
+}
+
+// backupUsage is the backups folder size on a day
+type backupUsage struct {
+	Day   time.Time
+	Bytes int64
+}
+
+// computeStats works out statistics from journal events (oldest first).
+// A session runs from session_start to session_end; a session without an end
+// (the app was killed) ends at its last event.
+func computeStats(events []historyEvent) historyStats {
+	var stats historyStats
+	if len(events) == 0 {
+		return stats
+	}
+	stats.First = events[0].Time
+	stats.Last = events[len(events)-1].Time
+
+	var inSession bool
+	var sessionStart, lastMark, lastEvent time.Time
+	unsaved := func(from, to time.Time) {
+		if d := to.Sub(from); d > stats.LongestUnsaved {
+			stats.LongestUnsaved = d
+			stats.LongestAt = from
+		}
+	}
+	closeSession := func(end time.Time) {
+		if !inSession {
+			return
+		}
+		stats.SessionTime += end.Sub(sessionStart)
+		unsaved(lastMark, end)
+		inSession = false
+	}
+
+	for _, e := range events {
+		switch e.Type {
+		case historySessionStart:
+			closeSession(lastEvent)
+			stats.Sessions++
+			inSession = true
+			sessionStart, lastMark = e.Time, e.Time
+		case historySessionEnd:
+			closeSession(e.Time)
+		case historySave:
+			stats.Saves++
+			if inSession {
+				if lastMark.After(sessionStart) {
+					stats.SaveIntervals = append(stats.SaveIntervals, e.Time.Sub(lastMark))
+				}
+				unsaved(lastMark, e.Time)
+				lastMark = e.Time
+			}
+			if e.TotalBytes > 0 {
+				day := time.Date(e.Time.Year(), e.Time.Month(), e.Time.Day(), 0, 0, 0, 0, e.Time.Location())
+				if n := len(stats.BackupUsage); n > 0 && stats.BackupUsage[n-1].Day.Equal(day) {
+					stats.BackupUsage[n-1].Bytes = e.TotalBytes
+				} else {
+					stats.BackupUsage = append(stats.BackupUsage, backupUsage{Day: day, Bytes: e.TotalBytes})
+				}
+			}
+		case historyBackupFailed:
+			stats.FailedBackups++
+		case historyAlarm:
+			stats.Alarms++
+		case historySnooze:
+			stats.Snoozes++
+		case historyAcknowledge:
+			stats.Acknowledges++
+		}
+		lastEvent = e.Time
+	}
+	closeSession(lastEvent)
+	return stats
+}
+
+// averageDuration returns the mean of a list of durations
+func averageDuration(durations []time.Duration) time.Duration {
+	if len(durations) == 0 {
+		return 0
+	}
+	var total time.Duration
+	for _, d := range durations {
+		total += d
+	}
+	return total / time.Duration(len(durations))
+}
+
+// formatBytes formats a size as KB, MB or GB
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1f GB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", n)
+}
+
+// runStats implements the stats command: it reads the history journal and
+// prints a summary. It doesn't need the reminder to be running.
+func runStats(config Config, args []string) int {
+	flags := flag.NewFlagSet("stats", flag.ContinueOnError)
+	days := flags.Int("days", 0, "only include the last N days (0 = all history)")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	path := resolveHistoryPath(config)
+	if path == "" {
+		fmt.Fprintln(os.Stderr, "History is disabled (history_file is \"none\" in config.json)")
+		return 1
+	}
+	events, err := readHistory(path)
+	if os.IsNotExist(err) {
+		fmt.Println("No history recorded yet.")
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not read history: %v\n", err)
+		return 1
+	}
+	if *days > 0 {
+		cutoff := time.Now().AddDate(0, 0, -*days)
+		first := sort.Search(len(events), func(i int) bool { return !events[i].Time.Before(cutoff) })
+		events = events[first:]
+	}
+	if len(events) == 0 {
+		fmt.Println("No history in this period.")
+		return 0
+	}
+
+	stats := computeStats(events)
+	fmt.Printf("Save history from %s to %s (%s)\n", stats.First.Format("2006-01-02"), stats.Last.Format("2006-01-02"), path)
+	fmt.Println()
+	fmt.Printf("Sessions:                %d", stats.Sessions)
+	if stats.Sessions > 0 {
+		fmt.Printf(" (%s played, %s on average)", spokenDuration(stats.SessionTime), spokenDuration(stats.SessionTime/time.Duration(stats.Sessions)))
+	}
+	fmt.Println()
+	fmt.Printf("Saves:                   %d", stats.Saves)
+	if stats.FailedBackups > 0 {
+		fmt.Printf(" (%d backups failed)", stats.FailedBackups)
+	}
+	fmt.Println()
+	if len(stats.SaveIntervals) > 0 {
+		fmt.Printf("Average save interval:   %s\n", spokenDuration(averageDuration(stats.SaveIntervals)))
+	}
+	if stats.LongestUnsaved > 0 {
+		fmt.Printf("Longest unsaved streak:  %s (from %s)\n", spokenDuration(stats.LongestUnsaved), stats.LongestAt.Format("2006-01-02 15:04"))
+	}
+	fmt.Printf("Alarms:                  %d", stats.Alarms)
+	if stats.Sessions > 0 {
+		fmt.Printf(" (%.1f per session)", float64(stats.Alarms)/float64(stats.Sessions))
+	}
+	fmt.Println()
+	fmt.Printf("Snoozes / acknowledges:  %d / %d\n", stats.Snoozes, stats.Acknowledges)
+
+	if len(stats.BackupUsage) > 0 {
+		fmt.Println()
+		fmt.Println("Backup disk usage:")
+		usage := stats.BackupUsage
+		const maxDays = 14
+		if len(usage) > maxDays {
+			usage = usage[len(usage)-maxDays:]
+		}
+		for i, u := range usage {
+			line := fmt.Sprintf("  %s  %10s", u.Day.Format("2006-01-02"), formatBytes(u.Bytes))
+			if i > 0 {
+				delta := u.Bytes - usage[i-1].Bytes
+				sign := "+"
+				if delta < 0 {
+					sign, delta = "-", -delta
+				}
+				line += fmt.Sprintf("  (%s%s)", sign, formatBytes(delta))
+			}
+			fmt.Println(strings.TrimRight(line, " "))
+		}
+	}
+	return 0
+}
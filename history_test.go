@@ -0,0 +1,124 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestComputeStats(t *testing.T) {
+	start := time.Date(2024, 3, 1, 20, 0, 0, 0, time.Local)
+	at := func(minutes int) time.Time { return start.Add(time.Duration(minutes) * time.Minute) }
+	events := []historyEvent{
+		// A session with saves after 10 and 25 minutes, ending 5 minutes later
+		{Time: at(0), Type: historySessionStart},
+		{Time: at(10), Type: historySave, TotalBytes: 100},
+		{Time: at(12), Type: historyAlarm},
+		{Time: at(13), Type: historySnooze},
+		{Time: at(25), Type: historySave, TotalBytes: 150},
+		{Time: at(30), Type: historySessionEnd},
+		// A save outside a session counts, but not towards intervals or streaks
+		{Time: at(60), Type: historySave},
+		// The next day: a session killed after 40 minutes without a save
+		{Time: at(24 * 60), Type: historySessionStart},
+		{Time: at(24*60 + 5), Type: historyBackupFailed},
+		{Time: at(24*60 + 20), Type: historySave, TotalBytes: 120},
+		{Time: at(24*60 + 30), Type: historyAcknowledge},
+		{Time: at(24*60 + 60), Type: historyAlarm},
+	}
+	stats := computeStats(events)
+
+	if !stats.First.Equal(at(0)) || !stats.Last.Equal(at(24*60+60)) {
+		t.Errorf("period = %v to %v, want %v to %v", stats.First, stats.Last, at(0), at(24*60+60))
+	}
+	if stats.Sessions != 2 || stats.SessionTime != 90*time.Minute {
+		t.Errorf("sessions = %d (%v), want 2 (1h30m0s)", stats.Sessions, stats.SessionTime)
+	}
+	if stats.Saves != 4 || stats.FailedBackups != 1 {
+		t.Errorf("saves = %d (%d failed), want 4 (1 failed)", stats.Saves, stats.FailedBackups)
+	}
+	if len(stats.SaveIntervals) != 1 || stats.SaveIntervals[0] != 15*time.Minute {
+		t.Errorf("save intervals = %v, want [15m0s]", stats.SaveIntervals)
+	}
+	if stats.LongestUnsaved != 40*time.Minute || !stats.LongestAt.Equal(at(24*60+20)) {
+		t.Errorf("longest unsaved = %v from %v, want 40m0s from %v", stats.LongestUnsaved, stats.LongestAt, at(24*60+20))
+	}
+	if stats.Alarms != 2 || stats.Snoozes != 1 || stats.Acknowledges != 1 {
+		t.Errorf("alarms/snoozes/acknowledges = %d/%d/%d, want 2/1/1", stats.Alarms, stats.Snoozes, stats.Acknowledges)
+	}
+	// One entry per day, with the day's last size
+	if len(stats.BackupUsage) != 2 || stats.BackupUsage[0].Bytes != 150 || stats.BackupUsage[1].Bytes != 120 ||
+		!stats.BackupUsage[1].Day.Equal(time.Date(2024, 3, 2, 0, 0, 0, 0, time.Local)) {
+		t.Errorf("backup usage = %+v, want 150 bytes on 2024-03-01 and 120 on 2024-03-02", stats.BackupUsage)
+	}
+
+	if empty := computeStats(nil); empty.Sessions != 0 || !empty.First.IsZero() {
+		t.Errorf("computeStats(nil) = %+v, want nothing", empty)
+	}
+}
+
+func TestComputeStatsUnclosedSession(t *testing.T) {
+	start := time.Date(2024, 3, 1, 20, 0, 0, 0, time.Local)
+	// The app was killed, then a new session started
+	events := []historyEvent{
+		{Time: start, Type: historySessionStart},
+		{Time: start.Add(20 * time.Minute), Type: historySave},
+		{Time: start.Add(time.Hour), Type: historySessionStart},
+		{Time: start.Add(time.Hour + 5*time.Minute), Type: historySave},
+		{Time: start.Add(time.Hour + 15*time.Minute), Type: historySave},
+		{Time: start.Add(time.Hour + 20*time.Minute), Type: historySessionEnd},
+	}
+	stats := computeStats(events)
+	// The first session ends at its last event, the save
+	if stats.Sessions != 2 || stats.SessionTime != 40*time.Minute {
+		t.Errorf("sessions = %d (%v), want 2 (40m0s)", stats.Sessions, stats.SessionTime)
+	}
+	if len(stats.SaveIntervals) != 1 || stats.SaveIntervals[0] != 10*time.Minute {
+		t.Errorf("save intervals = %v, want [10m0s]", stats.SaveIntervals)
+	}
+	if stats.LongestUnsaved != 20*time.Minute {
+		t.Errorf("longest unsaved = %v, want 20m0s", stats.LongestUnsaved)
+	}
+}
+
+func TestBackupsSizeRunningTotal(t *testing.T) {
+	backupsPath := t.TempDir()
+	writeBackup := func(name string, size int) backupInfo {
+		path := filepath.Join(backupsPath, name)
+		os.Mkdir(path, 0755)
+		if err := os.WriteFile(filepath.Join(path, "save.dat"), make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return backupInfo{Name: name, Path: path}
+	}
+	sr := &SaveReminder{backupsPath: backupsPath}
+	first := writeBackup("first", 100)
+
+	size := func() int64 {
+		total, err := sr.backupsSize()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return total
+	}
+	if got := size(); got != 100 {
+		t.Fatalf("measured size = %d, want 100", got)
+	}
+
+	// Added and deleted backups update the total without walking the folder
+	sr.backupAdded(writeBackup("second", 50).Path)
+	if err := sr.deleteBackup(first); err != nil {
+		t.Fatal(err)
+	}
+	writeBackup("by hand", 1000)
+	if got := size(); got != 50 {
+		t.Errorf("running total = %d, want 50", got)
+	}
+
+	// A new session measures the folder again
+	sr.startSession()
+	if got := size(); got != 1050 {
+		t.Errorf("size after a new session = %d, want 1050", got)
+	}
+}
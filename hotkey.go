@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Hotkey modifiers, with the values RegisterHotKey uses
+const (
+	modAlt   = 0x1
+	modCtrl  = 0x2
+	modShift = 0x4
+	modWin   = 0x8
+)
+
+// hotkey is a key combination such as Ctrl+Alt+C
+type hotkey struct {
+	modifiers uint32
+	key       uint32 // Windows virtual-key code
+}
+
+// parseHotkey parses a combination like "Ctrl+Alt+C" or "Shift+F9". At least
+// one modifier is required for letters and digits, so typing isn't taken over.
+func parseHotkey(spec string) (hotkey, error) {
+	var hk hotkey
+	parts := strings.Split(spec, "+")
+	for i, part := range parts {
+		part = strings.ToLower(strings.TrimSpace(part))
+		if i < len(parts)-1 {
+			switch part {
+			case "ctrl", "control":
+				hk.modifiers |= modCtrl
+			case "alt":
+				hk.modifiers |= modAlt
+			case "shift":
+				hk.modifiers |= modShift
+			case "win":
+				hk.modifiers |= modWin
+			default:
+				return hotkey{}, fmt.Errorf("unknown modifier %q in hotkey %q", part, spec)
+			}
+			continue
+		}
+
+		switch {
+		case len(part) == 1 && (part[0] >= 'a' && part[0] <= 'z' || part[0] >= '0' && part[0] <= '9'):
+			if hk.modifiers == 0 {
+				return hotkey{}, fmt.Errorf("hotkey %q needs a modifier such as Ctrl or Alt", spec)
+			}
+			hk.key = uint32(strings.ToUpper(part)[0]) // Virtual-key codes for letters and digits are their ASCII codes
+		case len(part) > 1 && part[0] == 'f':
+			var fn int
+			if _, err := fmt.Sscanf(part, "f%d", &fn); err != nil || fn < 1 || fn > 24 || part != fmt.Sprintf("f%d", fn) {
+				return hotkey{}, fmt.Errorf("unknown key %q in hotkey %q", part, spec)
+			}
+			hk.key = uint32(0x70 + fn - 1) // VK_F1 to VK_F24
+		case part == "pause":
+			hk.key = 0x13
+		case part == "scrolllock":
+			hk.key = 0x91
+		default:
+			return hotkey{}, fmt.Errorf("unknown key %q in hotkey %q", part, spec)
+		}
+	}
+	return hk, nil
+}
@@ -0,0 +1,11 @@
+//go:build !windows
+
+package main
+
+import "fmt"
+
+// registerHotkey is only implemented on Windows. Elsewhere, bind a desktop
+// shortcut to "nwn2-save-reminder checkpoint" instead.
+func registerHotkey(hk hotkey, action func()) error {
+	return fmt.Errorf("global hotkeys are only supported on Windows")
+}
@@ -0,0 +1,60 @@
+//go:build windows
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"unsafe"
+)
+
+var (
+	procRegisterHotKey = user32.NewProc("RegisterHotKey")
+	procGetMessage     = user32.NewProc("GetMessageW")
+)
+
+const (
+	modNoRepeat = 0x4000
+	wmHotkey    = 0x0312
+)
+
+// winMsg mirrors the Win32 MSG structure
+type winMsg struct {
+	hwnd    uintptr
+	message uint32
+	wParam  uintptr
+	lParam  uintptr
+	time    uint32
+	ptX     int32
+	ptY     int32
+}
+
+// registerHotkey registers a system-wide hotkey and calls action each time it
+// is pressed, also while another window such as the game has the focus.
+// Hotkey messages go to the thread that registered the hotkey, so it gets a
+// thread of its own with a message loop. Windows releases it when the process exits.
+func registerHotkey(hk hotkey, action func()) error {
+	registered := make(chan error, 1)
+	go func() {
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+
+		if ret, _, err := procRegisterHotKey.Call(0, 1, uintptr(hk.modifiers|modNoRepeat), uintptr(hk.key)); ret == 0 {
+			registered <- fmt.Errorf("RegisterHotKey failed (is another program using this hotkey?): %v", err)
+			return
+		}
+		registered <- nil
+
+		var msg winMsg
+		for {
+			ret, _, _ := procGetMessage.Call(uintptr(unsafe.Pointer(&msg)), 0, 0, 0)
+			if int32(ret) <= 0 {
+				return
+			}
+			if msg.message == wmHotkey {
+				go action()
+			}
+		}
+	}()
+	return <-registered
+}
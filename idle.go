@@ -0,0 +1,66 @@
+package main
+
+import (
+	"log/slog"
+	"time"
+)
+
+const (
+	// idlePauseReason is the pause reason used while the player is away
+	idlePauseReason = "player idle"
+	// idleCheckInterval is how often the idle time is polled
+	idleCheckInterval = 15 * time.Second
+)
+
+// IdleProvider reports how long it has been since the last keyboard or mouse input
+type IdleProvider interface {
+	IdleTime() (time.Duration, error)
+}
+
+// systemIdleProvider asks the operating system for the user's idle time
+type systemIdleProvider struct{}
+
+func (systemIdleProvider) IdleTime() (time.Duration, error) {
+	return systemIdleTime()
+}
+
+// watchIdle polls the idle provider every checkEvery and pauses alarms once
+// there has been no input for pauseAfter. The time spent idle doesn't count
+// towards the time since the last save, so the countdown carries on where it
+// left off.
+func (sr *SaveReminder) watchIdle(provider IdleProvider, pauseAfter, checkEvery time.Duration) {
+	ticker := time.NewTicker(checkEvery)
+	defer ticker.Stop()
+
+	isIdle := false
+	errorLogged := false
+	for {
+		select {
+		case <-sr.done:
+			return
+		case <-ticker.C:
+		}
+
+		idle, err := provider.IdleTime()
+		if err != nil {
+			// Without a reliable answer, never silence the reminder
+			if !errorLogged {
+				slog.Warn("Could not read idle time, alarms will not be paused when idle", "error", err)
+				errorLogged = true
+			}
+			idle = 0
+		} else {
+			errorLogged = false
+		}
+
+		if !isIdle && idle >= pauseAfter {
+			isIdle = true
+			slog.Info("No input, player seems to be away", "idle", idle.Round(time.Second))
+			sr.pauseAlarms(idlePauseReason)
+		} else if isIdle && idle < pauseAfter {
+			isIdle = false
+			slog.Info("Input detected, player is back")
+			sr.unpauseAlarms(idlePauseReason, false)
+		}
+	}
+}
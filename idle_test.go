@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeIdleAnswer is one reply of fakeIdleProvider
+type fakeIdleAnswer struct {
+	idle time.Duration
+	err  error
+}
+
+// fakeIdleProvider answers each check with the next value sent on answers
+type fakeIdleProvider struct {
+	answers chan fakeIdleAnswer
+	done    chan struct{}
+}
+
+func (p *fakeIdleProvider) IdleTime() (time.Duration, error) {
+	select {
+	case answer := <-p.answers:
+		return answer.idle, answer.err
+	case <-p.done:
+		return 0, nil
+	}
+}
+
+// watchFakeIdle starts watchIdle on sr with a fake provider and returns a
+// function that answers the next check. It returns once the answer has been
+// acted on.
+func watchFakeIdle(t *testing.T, sr *SaveReminder, pauseAfter time.Duration) func(idle time.Duration, err error) {
+	t.Helper()
+	sr.paused = make(map[string]time.Time)
+	sr.done = make(chan struct{})
+	provider := &fakeIdleProvider{answers: make(chan fakeIdleAnswer), done: sr.done}
+	finished := make(chan struct{})
+	go func() {
+		sr.watchIdle(provider, pauseAfter, time.Millisecond)
+		close(finished)
+	}()
+	t.Cleanup(func() {
+		close(sr.done)
+		<-finished
+		sr.resetAlarmTimers()
+	})
+	return func(idle time.Duration, err error) {
+		// The provider is only asked again once the last answer was dealt with
+		provider.answers <- fakeIdleAnswer{idle, err}
+		provider.answers <- fakeIdleAnswer{idle, err}
+	}
+}
+
+func TestWatchIdle(t *testing.T) {
+	lastSave := time.Now().Add(-20 * time.Minute)
+	sr := &SaveReminder{config: Config{AlarmInterval: "1h"}, lastSaveTime: lastSave}
+	check := watchFakeIdle(t, sr, 5*time.Minute)
+
+	state := func() (paused bool, lastSave, nextAlarm time.Time) {
+		sr.mu.Lock()
+		defer sr.mu.Unlock()
+		_, paused = sr.paused[idlePauseReason]
+		return paused, sr.lastSaveTime, sr.nextAlarmAt
+	}
+
+	check(4*time.Minute, nil)
+	if paused, _, _ := state(); paused {
+		t.Fatal("paused before the idle threshold")
+	}
+
+	check(5*time.Minute, nil)
+	if paused, _, nextAlarm := state(); !paused || !nextAlarm.IsZero() {
+		t.Fatalf("idle for pause_after: paused = %v, next alarm at %v, want paused with no alarm", paused, nextAlarm)
+	}
+	check(time.Hour, nil)
+	if paused, _, _ := state(); !paused {
+		t.Fatal("unpaused while still idle")
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	check(time.Second, nil)
+	paused, newLastSave, nextAlarm := state()
+	if paused {
+		t.Fatal("still paused after input")
+	}
+	// The countdown carries on: only the idle time is added to the last save
+	if newLastSave.Before(lastSave.Add(50*time.Millisecond)) || newLastSave.After(lastSave.Add(time.Minute)) {
+		t.Errorf("last save moved from %v to %v, want it shifted by the time spent idle", lastSave, newLastSave)
+	}
+	if want := newLastSave.Add(time.Hour); nextAlarm.Before(want.Add(-time.Second)) || nextAlarm.After(want.Add(time.Second)) {
+		t.Errorf("next alarm at %v, want alarm_interval after the shifted last save (%v)", nextAlarm, want)
+	}
+
+	// Without an answer from the system, the reminder is never silenced
+	check(time.Hour, nil)
+	check(0, errors.New("no idle time"))
+	if paused, _, _ := state(); paused {
+		t.Error("still paused after the idle time could not be read")
+	}
+}
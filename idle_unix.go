@@ -0,0 +1,80 @@
+//go:build !windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// systemIdleTime tries the X11 screensaver extension (via xprintidle) first,
+// then falls back to the systemd-logind idle hint for the current session
+func systemIdleTime() (time.Duration, error) {
+	x11Idle, x11Err := x11IdleTime()
+	if x11Err == nil {
+		return x11Idle, nil
+	}
+	logindIdle, logindErr := logindIdleTime()
+	if logindErr == nil {
+		return logindIdle, nil
+	}
+	return 0, fmt.Errorf("%v; %v", x11Err, logindErr)
+}
+
+// x11IdleTime reads the X11 screensaver idle counter using xprintidle
+func x11IdleTime() (time.Duration, error) {
+	if os.Getenv("DISPLAY") == "" {
+		return 0, fmt.Errorf("X11 idle time unavailable: DISPLAY is not set")
+	}
+	output, err := exec.Command("xprintidle").Output()
+	if err != nil {
+		return 0, fmt.Errorf("failed to run xprintidle: %v", err)
+	}
+	ms, err := strconv.ParseInt(strings.TrimSpace(string(output)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse xprintidle output: %v", err)
+	}
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+// logindIdleTime reads the IdleHint of the current logind session.
+// The desktop environment sets the hint once its own idle timeout passes.
+func logindIdleTime() (time.Duration, error) {
+	session := os.Getenv("XDG_SESSION_ID")
+	if session == "" {
+		session = "self"
+	}
+	output, err := exec.Command("loginctl", "show-session", session, "--property=IdleHint", "--property=IdleSinceHint").Output()
+	if err != nil {
+		return 0, fmt.Errorf("failed to run loginctl: %v", err)
+	}
+
+	var idleHint bool
+	var idleSince int64
+	for _, line := range strings.Split(string(output), "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "IdleHint":
+			idleHint = value == "yes"
+		case "IdleSinceHint":
+			// Microseconds since the Unix epoch
+			idleSince, _ = strconv.ParseInt(value, 10, 64)
+		}
+	}
+
+	if !idleHint || idleSince == 0 {
+		return 0, nil
+	}
+	idle := time.Since(time.UnixMicro(idleSince))
+	if idle < 0 {
+		idle = 0
+	}
+	return idle, nil
+}
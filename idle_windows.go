@@ -0,0 +1,35 @@
+//go:build windows
+
+package main
+
+import (
+	"fmt"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+var (
+	user32               = syscall.NewLazyDLL("user32.dll")
+	kernel32             = syscall.NewLazyDLL("kernel32.dll")
+	procGetLastInputInfo = user32.NewProc("GetLastInputInfo")
+	procGetTickCount     = kernel32.NewProc("GetTickCount")
+)
+
+// lastInputInfo mirrors the Win32 LASTINPUTINFO structure
+type lastInputInfo struct {
+	cbSize uint32
+	dwTime uint32
+}
+
+// systemIdleTime uses GetLastInputInfo, which covers input to any application in the session
+func systemIdleTime() (time.Duration, error) {
+	info := lastInputInfo{cbSize: uint32(unsafe.Sizeof(lastInputInfo{}))}
+	if ret, _, err := procGetLastInputInfo.Call(uintptr(unsafe.Pointer(&info))); ret == 0 {
+		return 0, fmt.Errorf("GetLastInputInfo failed: %v", err)
+	}
+
+	// Both values are 32-bit millisecond tick counts; unsigned subtraction handles wrap-around
+	tick, _, _ := procGetTickCount.Call()
+	return time.Duration(uint32(tick)-info.dwTime) * time.Millisecond, nil
+}
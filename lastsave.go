@@ -0,0 +1,60 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// detectLastSave works out when the game was last saved, for the first alarm
+// after startup. A folder's own modification time often stays the same when
+// the files in it are rewritten, so it looks at the newest file in each slot
+// that counts as a save, and at the newest backup of one, in case the files'
+// times were lost (e.g. copied without them). It returns the newest of these
+// and where it came from, or the zero time if there's nothing to go on.
+func (sr *SaveReminder) detectLastSave() (time.Time, string) {
+	var newest time.Time
+	var source string
+	consider := func(t time.Time, from string) {
+		// Clocks and copied files can put times in the future
+		if t.After(time.Now()) {
+			t = time.Now()
+		}
+		if t.After(newest) {
+			newest, source = t, from
+		}
+	}
+
+	quicksaveFolder := filepath.Join(sr.savesPath, quicksaveName)
+	consider(newestModTime(quicksaveFolder, nil), "quicksave files")
+	if sr.config.VaultBackup.Enabled && sr.config.VaultBackup.CountsAsSave {
+		userFolder := nwn2UserFolder(sr.savesPath)
+		for _, path := range sr.config.VaultBackup.Paths {
+			consider(newestModTime(filepath.Join(userFolder, path), nil), "character vault")
+		}
+	}
+
+	if backups, err := listBackups(sr.backupsPath); err == nil {
+		for _, b := range backups {
+			if sr.backupIsSave(b) {
+				consider(b.Time, "backup "+b.Name)
+				break
+			}
+		}
+	}
+	if sr.gitBackup != nil {
+		if t, ok := sr.gitBackup.lastCommitTime(); ok {
+			consider(t, "git backup repository")
+		}
+	}
+	return newest, source
+}
+
+// backupIsSave reports whether a backup was made for a detected save, as
+// opposed to a checkpoint, a safety copy before a restore or a snapshot
+func (sr *SaveReminder) backupIsSave(b backupInfo) bool {
+	if strings.HasPrefix(b.Label, vaultLabelPrefix) {
+		return sr.config.VaultBackup.CountsAsSave
+	}
+	return baseLabel(b.Label) == quicksaveName
+}
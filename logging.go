@@ -0,0 +1,313 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultLogFile is the log file name used when log_file is not set
+const defaultLogFile = "nwn2-save-reminder.log"
+
+// consoleTimeFormat matches the standard log package's timestamps
+const consoleTimeFormat = "2006/01/02 15:04:05"
+
+// consoleOutput is where console log lines go; the TUI redirects it to its log pane
+var consoleOutput = &switchWriter{w: os.Stderr}
+
+// switchWriter is a writer whose destination can be changed while in use
+type switchWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (s *switchWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Write(p)
+}
+
+// setConsoleOutput changes where console log lines are written
+func setConsoleOutput(w io.Writer) {
+	consoleOutput.mu.Lock()
+	consoleOutput.w = w
+	consoleOutput.mu.Unlock()
+}
+
+// parseLogLevel parses "debug", "info", "warn" or "error"
+func parseLogLevel(level string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(level)) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return slog.LevelInfo, fmt.Errorf("unknown log level %q (use debug, info, warn or error)", level)
+}
+
+// setupLogging installs the default logger: human-readable text on the console
+// and, unless log_file is "none", JSON lines in a size-rotated file. It returns a
+// function that closes the log file.
+func setupLogging(config Config) func() {
+	level, levelErr := parseLogLevel(config.LogLevel)
+	handlers := []slog.Handler{newConsoleHandler(consoleOutput, level)}
+	closeFile := func() {}
+	var fileErr error
+	if config.LogFile != "none" {
+		file, err := newRotatingFile(resolveExePath(config.LogFile), int64(config.LogMaxSizeMB)*1024*1024, config.LogMaxFiles)
+		if err != nil {
+			fileErr = err
+		} else {
+			handlers = append(handlers, slog.NewJSONHandler(file, &slog.HandlerOptions{Level: level}))
+			closeFile = func() { file.Close() }
+		}
+	}
+
+	// This also sends anything still using the log package through the same handlers
+	slog.SetDefault(slog.New(fanoutHandler(handlers)))
+	if levelErr != nil {
+		slog.Warn("Invalid log_level in config, using info", "error", levelErr)
+	}
+	if fileErr != nil {
+		slog.Warn("Log file disabled", "error", fileErr)
+	}
+	return closeFile
+}
+
+// fanoutHandler sends every record to several handlers
+type fanoutHandler []slog.Handler
+
+func (f fanoutHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range f {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f fanoutHandler) Handle(ctx context.Context, r slog.Record) error {
+	var firstErr error
+	for _, h := range f {
+		if !h.Enabled(ctx, r.Level) {
+			continue
+		}
+		if err := h.Handle(ctx, r.Clone()); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (f fanoutHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make(fanoutHandler, len(f))
+	for i, h := range f {
+		handlers[i] = h.WithAttrs(attrs)
+	}
+	return handlers
+}
+
+func (f fanoutHandler) WithGroup(name string) slog.Handler {
+	handlers := make(fanoutHandler, len(f))
+	for i, h := range f {
+		handlers[i] = h.WithGroup(name)
+	}
+	return handlers
+}
+
+// consoleHandler writes records as readable lines:
+//
+//	2024/01/02 20:15:00 WARNING: Could not play audio error="device busy"
+type consoleHandler struct {
+	w      io.Writer
+	level  slog.Leveler
+	attrs  []slog.Attr
+	prefix string // Group prefix for attribute keys
+}
+
+func newConsoleHandler(w io.Writer, level slog.Leveler) *consoleHandler {
+	return &consoleHandler{w: w, level: level}
+}
+
+func (h *consoleHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *consoleHandler) Handle(_ context.Context, r slog.Record) error {
+	var b strings.Builder
+	b.WriteString(r.Time.Format(consoleTimeFormat))
+	b.WriteByte(' ')
+	switch {
+	case r.Level >= slog.LevelError:
+		b.WriteString("ERROR: ")
+	case r.Level >= slog.LevelWarn:
+		b.WriteString("WARNING: ")
+	case r.Level < slog.LevelInfo:
+		b.WriteString("DEBUG: ")
+	}
+	b.WriteString(r.Message)
+
+	for _, a := range h.attrs {
+		writeConsoleAttr(&b, "", a)
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		writeConsoleAttr(&b, h.prefix, a)
+		return true
+	})
+	b.WriteByte('\n')
+
+	_, err := io.WriteString(h.w, b.String())
+	return err
+}
+
+func (h *consoleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	clone.attrs = append([]slog.Attr(nil), h.attrs...)
+	for _, a := range attrs {
+		a.Key = h.prefix + a.Key
+		clone.attrs = append(clone.attrs, a)
+	}
+	return &clone
+}
+
+func (h *consoleHandler) WithGroup(name string) slog.Handler {
+	clone := *h
+	clone.prefix = h.prefix + name + "."
+	return &clone
+}
+
+// writeConsoleAttr appends " key=value", quoting values that contain spaces
+func writeConsoleAttr(b *strings.Builder, prefix string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		for _, ga := range a.Value.Group() {
+			writeConsoleAttr(b, prefix+a.Key+".", ga)
+		}
+		return
+	}
+
+	var value string
+	switch a.Value.Kind() {
+	case slog.KindTime:
+		value = a.Value.Time().Format(consoleTimeFormat)
+	case slog.KindDuration:
+		value = a.Value.Duration().Round(time.Millisecond).String()
+	default:
+		value = a.Value.String()
+	}
+	if value == "" || strings.ContainsAny(value, " \"=") {
+		quoted, _ := json.Marshal(value)
+		value = string(quoted)
+	}
+	fmt.Fprintf(b, " %s%s=%s", prefix, a.Key, value)
+}
+
+// rotatingFile is a log file that is rotated when it grows past maxSize.
+// Old files are kept as name.1 (newest) to name.N.
+type rotatingFile struct {
+	mu       sync.Mutex
+	path     string
+	maxSize  int64
+	maxFiles int
+	file     *os.File
+	size     int64
+}
+
+func newRotatingFile(path string, maxSize int64, maxFiles int) (*rotatingFile, error) {
+	if maxSize <= 0 {
+		maxSize = 10 * 1024 * 1024
+	}
+	if maxFiles < 0 {
+		maxFiles = 0
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create log folder: %v", err)
+	}
+	r := &rotatingFile{path: path, maxSize: maxSize, maxFiles: maxFiles}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %v", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to read log file: %v", err)
+	}
+	r.file = file
+	r.size = info.Size()
+	return nil
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return 0, fmt.Errorf("log file is closed")
+	}
+	if r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			// Keep logging to the current file rather than losing records
+			fmt.Fprintf(os.Stderr, "WARNING: Log rotation failed: %v\n", err)
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// rotate shifts name.1..name.N-1 up by one, moves the current file to name.1
+// and starts a new file
+func (r *rotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+	r.file = nil
+
+	if r.maxFiles == 0 {
+		os.Remove(r.path)
+	} else {
+		os.Remove(fmt.Sprintf("%s.%d", r.path, r.maxFiles))
+		for i := r.maxFiles - 1; i >= 1; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", r.path, i), fmt.Sprintf("%s.%d", r.path, i+1))
+		}
+		if err := os.Rename(r.path, r.path+".1"); err != nil {
+			// Reopen the old file so logging carries on
+			r.open()
+			return err
+		}
+	}
+	return r.open()
+}
+
+func (r *rotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
@@ -2,23 +2,22 @@ package main
 
 import (
 	"encoding/json"
+	"flag"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 	"os/exec"
+	"net"
 	"os/signal"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
-	"github.com/gopxl/beep"
-	"github.com/gopxl/beep/mp3"
-	"github.com/gopxl/beep/speaker"
-	"github.com/gopxl/beep/wav"
-	"github.com/gopxl/beep/effects"
 )
 
 const (
@@ -32,9 +31,57 @@ type Config struct {
 	AlarmInterval  string `json:"alarm_interval"`   // Time before first alarm (e.g., "5m", "300s")
 	DebounceDelay  string `json:"debounce_delay"`   // Wait time after file change (e.g., "3s")
 	RepeatInterval string `json:"repeat_interval"`   // Time between repeat alarms (e.g., "5m")
-	AlarmSoundFile string `json:"alarm_sound_file"`  // Path to audio file (empty = system beep)
+	AlarmSoundFile string `json:"alarm_sound_file"`  // Path to audio file (empty = built-in tone)
+	AlarmTone      ToneConfig `json:"alarm_tone"`   // Built-in tone pattern used when no sound file is set
 	AlarmVolume    int    `json:"alarm_volume"`     // Alarm volume (0-100, default: 100)
-	VerboseLogging bool   `json:"verbose_logging"`  // Enable verbose/debug logging
+	AudioDevice    string `json:"audio_device"`     // Output device ("" = system default, "none" = no audio, device name on Windows, ALSA card on Linux without PulseAudio/PipeWire)
+	NormalizeLoudness bool `json:"normalize_loudness"` // Bring every sound to the same loudness before applying the volume
+	AlarmFadeIn      string `json:"alarm_fade_in"`      // Fade the alarm in from silence over this long (e.g., "3s", "0s" = off)
+	AlarmLoop        bool   `json:"alarm_loop"`         // Repeat the alarm sound until acknowledged, saved or alarm_max_duration
+	AlarmMaxDuration string `json:"alarm_max_duration"` // Longest a single alarm may play (e.g., "60s")
+	AlarmMode      string `json:"alarm_mode"`       // "sound", "speech" or "sound+speech"
+	SpeechMessage  string `json:"speech_message"`   // Spoken reminder template ({elapsed}, {minutes}, {time})
+	TTSBackend     string `json:"tts_backend"`      // "auto", "sapi", "espeak" or "piper"
+	PiperModel     string `json:"piper_model"`      // Voice model for the piper backend (.onnx)
+	LogLevel       string `json:"log_level"`        // "debug", "info", "warn" or "error"
+	LogFile        string `json:"log_file"`         // JSON log file, relative to the executable ("none" = disabled)
+	LogMaxSizeMB   int    `json:"log_max_size_mb"`  // Rotate the log file when it reaches this size
+	LogMaxFiles    int    `json:"log_max_files"`    // Rotated log files to keep
+	VerboseLogging bool   `json:"verbose_logging,omitempty"` // Deprecated: same as log_level "debug"
+	HistoryFile    string `json:"history_file"`     // Save history journal, relative to the executable ("none" = disabled)
+	StateFile      string `json:"state_file"`       // Reminder state kept across restarts, relative to the executable ("none" = disabled)
+	AdaptiveInterval    bool   `json:"adaptive_interval"`     // Learn alarm_interval from how often the player saves
+	AdaptiveMinInterval string `json:"adaptive_min_interval"` // Shortest adaptive alarm interval (e.g., "3m")
+	AdaptiveMaxInterval string `json:"adaptive_max_interval"` // Longest adaptive alarm interval (e.g., "15m")
+	BackupMode          string   `json:"backup_mode"`           // "folders", "git" or "both"
+	GitRepository       string   `json:"git_repository"`        // Repository for git backups, relative to the backups folder
+	ReplicationTargets  []string `json:"replication_targets"`   // Extra folders (e.g., a NAS mount) that get a copy of every backup
+	S3                  S3Config `json:"s3"`                    // S3-compatible bucket that gets an archive of every backup
+	Retention           RetentionPolicy `json:"retention"`      // How many backups to keep, locally and in the S3 bucket
+	Encryption          EncryptionConfig `json:"encryption"`    // Encrypt backups copied to replication targets and the S3 bucket
+	FullSnapshot        FullSnapshotConfig `json:"full_snapshot"` // Periodic backups of the vaults, all saves, override and ini files
+	VaultBackup         VaultBackupConfig  `json:"vault_backup"`  // Back up character files as the game writes them to a vault
+	AlarmEscalation []EscalationStep `json:"alarm_escalation,omitempty"` // Optional escalation schedule (empty = fixed repeat)
+	SnoozeDuration string `json:"snooze_duration"`  // Default snooze length (e.g., "10m")
+	ControlAddress string `json:"control_address"`  // Local control socket address (empty = disabled)
+	CheckpointHotkey string `json:"checkpoint_hotkey"` // Global hotkey that makes a checkpoint backup (e.g., "Ctrl+Alt+C", empty = disabled)
+	PauseWhenGameClosed bool     `json:"pause_when_game_closed"` // Suspend alarms while NWN2 is not running
+	GameProcessNames    []string `json:"game_process_names"`     // Executable names that count as the game running
+	GameCheckInterval   string   `json:"game_check_interval"`    // How often to look for the game process (e.g., "10s")
+	IdlePauseAfter      string   `json:"idle_pause_after"`       // Pause alarms after this long without input (empty = disabled)
+	AlarmSchedule       []ScheduleWindow `json:"alarm_schedule,omitempty"`  // Times when alarms may sound (empty = any time)
+	SessionWindows      []ScheduleWindow `json:"session_windows,omitempty"` // Session mode: only run the reminder inside these windows (empty = always)
+	TrayMode            bool             `json:"tray_mode"`                 // Run as a system tray icon instead of in the console
+}
+
+// EscalationStep describes one stage of the alarm escalation schedule.
+// A step takes effect once the time since the last save reaches After and
+// stays in effect until the next step starts.
+type EscalationStep struct {
+	After     string `json:"after"`                // Time since last save when this step starts (e.g., "10m")
+	Repeat    string `json:"repeat,omitempty"`     // Time between alarms during this step (empty = repeat_interval)
+	SoundFile string `json:"sound_file,omitempty"` // Audio file for this step (empty = alarm_sound_file)
+	Volume    *int   `json:"volume,omitempty"`     // Volume for this step (0-100, 0 = muted, unset = alarm_volume)
 }
 
 // DefaultConfig returns the default configuration
@@ -44,8 +91,39 @@ func DefaultConfig() Config {
 		DebounceDelay:  "3s",
 		RepeatInterval: "5m",
 		AlarmSoundFile: "",
+		AlarmTone:      defaultTone(),
 		AlarmVolume:    100,
-		VerboseLogging: false,
+		AudioDevice:    "",
+		NormalizeLoudness: true,
+		AlarmFadeIn:      "0s",
+		AlarmLoop:        false,
+		AlarmMaxDuration: "60s",
+		AlarmMode:      alarmModeSound,
+		SpeechMessage:  defaultSpeechMessage,
+		TTSBackend:     "auto",
+		PiperModel:     "",
+		LogLevel:       "info",
+		LogFile:        defaultLogFile,
+		LogMaxSizeMB:   10,
+		LogMaxFiles:    5,
+		HistoryFile:    defaultHistoryFile,
+		StateFile:      defaultStateFile,
+		AdaptiveInterval:    false,
+		AdaptiveMinInterval: "3m",
+		AdaptiveMaxInterval: "15m",
+		BackupMode:          backupModeFolders,
+		GitRepository:       defaultGitRepository,
+		ReplicationTargets:  []string{},
+		FullSnapshot:        FullSnapshotConfig{Interval: "", OnShutdown: false, Paths: defaultFullSnapshotPaths()},
+		VaultBackup:         VaultBackupConfig{Enabled: false, Paths: defaultVaultPaths(), CountsAsSave: false},
+		SnoozeDuration: "10m",
+		ControlAddress: "127.0.0.1:47823",
+		CheckpointHotkey: "",
+		PauseWhenGameClosed: true,
+		GameProcessNames:    defaultGameProcessNames(),
+		GameCheckInterval:   "10s",
+		IdlePauseAfter:      "",
+		TrayMode:            false,
 	}
 }
 
@@ -54,50 +132,144 @@ type SaveReminder struct {
 	backupsPath       string
 	watcher           *fsnotify.Watcher
 	lastSaveTime      time.Time
+	lastSaveFound     bool // lastSaveTime came from a save found at startup, not from the startup time
 	alarmTimer        *time.Timer
-	repeatTimer       *time.Ticker
+	alarmGen          uint64
+	nextAlarmAt       time.Time
 	alarmActive       bool
+	escalation        []alarmStage
+	alarmSchedule     []scheduleWindow
+	sessionWindows    []scheduleWindow
+	snoozeUntil       time.Time
+	acknowledged      bool
+	paused            map[string]time.Time // Reasons alarms are paused, with when each started
+	done              chan struct{}
+	controlListener   net.Listener
+	controlToken      string // Secret a control socket client must send first
+	ignoreEventsUntil time.Time // Quicksave changes before this time come from a restore
+	mu                sync.Mutex
 	debounceTimer     *time.Timer
 	config            Config
-	verbose           bool
-	speakerInitialized bool
+	audio             *audioPlayer
+	playback          *playback // Alarm sound currently playing, if any
+	tts               TTSBackend
+	saveEvents        []saveEvent // Most recent saves, oldest first
+	history           *historyJournal
+	gitBackup         *gitBackup  // Repository for git backups (nil = backup_mode has no git)
+	replicator        *replicator // Copies backups to the replication targets (nil = none configured)
+	snapshotMu        sync.Mutex  // Held while a full snapshot is being taken
+	statePath         string      // State file ("" = state is not saved)
+	stateMu           sync.Mutex  // Serializes state file writes
+	stopped           bool        // Shut down cleanly
+	pendingBackup     time.Time   // When a quicksave change waiting for the debounce was first seen
+	lastAlarmStep     int         // Escalation step of the last alarm since the last save
+	inSession         bool // A play session is open in the history
+	savedThisSession  bool // A save has been detected since the session started
+	backupsBytes      int64 // Running total of the backups folder's size
+	backupsMeasured   bool  // backupsBytes has been measured this session
+	saveIntervals     []time.Duration // Recent times between saves, oldest first
+	sessionIntervals  []time.Duration // Times between saves in the current session
+	adaptiveDelay     time.Duration   // Learned alarm interval (0 = not adaptive)
+	tone              *sound          // Built-in alarm tone, rendered when the config loads (nil = unavailable)
+}
+
+// maxSaveEvents is how many recent saves are kept for display
+const maxSaveEvents = 20
+
+// saveEvent records a detected save and the backup made for it
+type saveEvent struct {
+	Time   time.Time
+	Backup string // Backup folder name (empty if the backup failed)
+	Err    error  // Why the backup failed
+}
+
+// recordSaveEvent adds a save to the recent saves list
+func (sr *SaveReminder) recordSaveEvent(event saveEvent) {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	sr.saveEvents = append(sr.saveEvents, event)
+	if len(sr.saveEvents) > maxSaveEvents {
+		sr.saveEvents = sr.saveEvents[len(sr.saveEvents)-maxSaveEvents:]
+	}
+}
+
+// newestSaveEvent returns the time of the most recent save event (zero if none)
+func (sr *SaveReminder) newestSaveEvent() time.Time {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	if len(sr.saveEvents) == 0 {
+		return time.Time{}
+	}
+	return sr.saveEvents[len(sr.saveEvents)-1].Time
 }
 
 func main() {
 	// Load configuration
 	config, err := loadConfig()
 	if err != nil {
-		log.Printf("WARNING: Could not load config, using defaults: %v", err)
+		slog.Warn("Could not load config, using defaults", "error", err)
 		config = DefaultConfig()
 	}
 	
-	// Get the Documents folder path (handles custom locations)
-	documentsPath, err := getDocumentsFolder()
+	// "run" (or just flags) starts the reminder; anything else is a control
+	// command (snooze, ack, status, ...) for the running instance
+	args := os.Args[1:]
+	if len(args) > 0 && args[0] == "stats" {
+		os.Exit(runStats(config, args[1:]))
+	}
+	if len(args) > 0 && args[0] == "sync" {
+		os.Exit(runSync(config, args[1:]))
+	}
+	if len(args) > 0 && args[0] == "s3" {
+		os.Exit(runS3(config, args[1:]))
+	}
+	if len(args) > 0 && args[0] == "restore" {
+		os.Exit(runRestore(config, args[1:]))
+	}
+	if len(args) > 0 && args[0] == "verify" {
+		os.Exit(runVerify(config, args[1:]))
+	}
+	if len(args) > 0 && args[0] == "list" {
+		os.Exit(runList(config, args[1:]))
+	}
+	if len(args) > 0 && (args[0] == "pin" || args[0] == "unpin" || args[0] == "note") {
+		os.Exit(runEditBackup(config, args[0], args[1:]))
+	}
+	if len(args) > 0 && args[0] == "run" {
+		args = args[1:]
+	} else if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		os.Exit(runControlClient(config, args))
+	}
+	options, err := parseRunOptions(args, config)
 	if err != nil {
-		log.Printf("WARNING: Could not determine Documents folder, using default: %v", err)
-		// Fallback to standard location
-		documentsPath = filepath.Join(os.Getenv("USERPROFILE"), "Documents")
+		os.Exit(2)
+	}
+	closeLog := setupLogging(config)
+	defer closeLog()
+	if options.tray {
+		if err := trayAvailable(); err != nil {
+			slog.Warn("System tray unavailable, running in the console", "error", err)
+			options.tray = false
+		}
 	}
 	
-	// Get the saves folder path
-	savesPath := filepath.Join(documentsPath, "Neverwinter Nights 2", "saves", "multiplayer")
+	documentsPath, savesPath := getSavesFolder()
 	
-	log.Printf("NWN2 Save Reminder starting...")
-	log.Printf("Documents folder: %s", documentsPath)
-	log.Printf("Watching folder: %s", savesPath)
-	log.Printf("Configuration loaded from: %s", getConfigPath())
+	slog.Info("NWN2 Save Reminder starting...")
+	slog.Info("Documents folder", "path", documentsPath)
+	slog.Info("Watching folder", "path", savesPath)
+	slog.Info("Configuration loaded", "path", getConfigPath())
 	
 	// Print configuration
 	printConfig(config)
 	
 	// Check if folder exists
 	if _, err := os.Stat(savesPath); os.IsNotExist(err) {
-		log.Printf("ERROR: Saves folder does not exist: %s", savesPath)
-		log.Printf("")
-		log.Printf("Please make sure:")
-		log.Printf("1. Neverwinter Nights 2 has been launched at least once")
-		log.Printf("2. You have created a multiplayer save at least once")
-		log.Printf("3. The folder path is correct")
+		slog.Error("Saves folder does not exist", "path", savesPath)
+		slog.Info("Please make sure:")
+		slog.Info("1. Neverwinter Nights 2 has been launched at least once")
+		slog.Info("2. You have created a multiplayer save at least once")
+		slog.Info("3. The folder path is correct")
 		pauseBeforeExit("")
 		os.Exit(1)
 	}
@@ -105,7 +277,7 @@ func main() {
 	// Create backups folder
 	backupsPath := filepath.Join(savesPath, backupFolderName)
 	if err := os.MkdirAll(backupsPath, 0755); err != nil {
-		log.Printf("ERROR: Failed to create backups folder: %v", err)
+		slog.Error("Failed to create backups folder", "error", err)
 		pauseBeforeExit("")
 		os.Exit(1)
 	}
@@ -113,32 +285,89 @@ func main() {
 	// Create watcher
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
-		log.Printf("ERROR: Failed to create file watcher: %v", err)
+		slog.Error("Failed to create file watcher", "error", err)
 		pauseBeforeExit("")
 		os.Exit(1)
 	}
 	defer watcher.Close()
 	
+	alarmSchedule, err := parseSchedule("alarm_schedule", config.AlarmSchedule)
+	if err != nil {
+		slog.Error("Invalid schedule in config", "error", err)
+		pauseBeforeExit("")
+		os.Exit(1)
+	}
+	sessionWindows, err := parseSchedule("session_windows", config.SessionWindows)
+	if err != nil {
+		slog.Error("Invalid schedule in config", "error", err)
+		pauseBeforeExit("")
+		os.Exit(1)
+	}
+	
 	reminder := &SaveReminder{
 		savesPath:   savesPath,
 		backupsPath: backupsPath,
 		watcher:     watcher,
 		config:      config,
-		verbose:     config.VerboseLogging,
+		escalation:  buildEscalation(config),
+		alarmSchedule:  alarmSchedule,
+		sessionWindows: sessionWindows,
+		audio:       newAudioPlayer(config.AudioDevice, config.NormalizeLoudness),
+		paused:      make(map[string]time.Time),
+		done:        make(chan struct{}),
+	}
+	// The built-in tone is rendered once; loadConfig has already checked it
+	if tone, err := renderTone(config.AlarmTone); err != nil {
+		slog.Warn("Built-in alarm tone unavailable", "error", err)
+	} else {
+		reminder.tone = tone
+	}
+	
+	// Record saves, alarms and snoozes for the stats command
+	if historyPath := resolveHistoryPath(config); historyPath != "" {
+		if history, err := openHistory(historyPath); err != nil {
+			slog.Warn("Save history disabled", "error", err)
+		} else {
+			reminder.history = history
+			defer history.Close()
+		}
+		if config.AdaptiveInterval {
+			reminder.loadSaveIntervals(historyPath)
+		}
+	}
+	reminder.updateAdaptiveInterval()
+	
+	// Commit saves to a git repository
+	if backupModeIncludes(config, backupModeGit) {
+		repoPath := resolveGitRepository(config, backupsPath)
+		if gitBackup, err := openGitBackup(repoPath); err != nil {
+			slog.Error("Git backups disabled, making folder backups instead", "error", err)
+			reminder.config.BackupMode = backupModeFolders
+		} else {
+			reminder.gitBackup = gitBackup
+		}
+	}
+	
+	// Copy new backups to the replication targets in the background
+	if sinks, err := replicationSinks(config); err != nil {
+		slog.Error("Backup replication disabled", "error", err)
+	} else if len(sinks) > 0 {
+		reminder.replicator = newReplicator(backupsPath, sinks)
+		go reminder.replicator.run(reminder.done)
 	}
 	
 	// Find the quicksave folder
 	quicksaveFolder := filepath.Join(savesPath, quicksaveName)
 	if _, err := os.Stat(quicksaveFolder); os.IsNotExist(err) {
-		log.Printf("WARNING: Quicksave folder does not exist yet: %s", quicksaveFolder)
-		log.Printf("The watcher will start monitoring once the folder is created.")
+		slog.Warn("Quicksave folder does not exist yet", "path", quicksaveFolder)
+		slog.Info("The watcher will start monitoring once the folder is created.")
 	} else {
-		log.Printf("Found quicksave folder: %s", quicksaveFolder)
+		slog.Info("Found quicksave folder", "path", quicksaveFolder)
 	}
 	
 	// Add both the saves folder (to detect new folders) and quicksave folder (to detect changes)
 	if err := watcher.Add(savesPath); err != nil {
-		log.Printf("ERROR: Failed to add saves folder to watcher: %v", err)
+		slog.Error("Failed to add saves folder to watcher", "error", err)
 		pauseBeforeExit("")
 		os.Exit(1)
 	}
@@ -146,48 +375,60 @@ func main() {
 	// Also watch the quicksave folder if it exists (for changes within it)
 	if _, err := os.Stat(quicksaveFolder); err == nil {
 		if err := watcher.Add(quicksaveFolder); err != nil {
-			log.Printf("WARNING: Failed to add quicksave folder to watcher: %v", err)
+			slog.Warn("Failed to add quicksave folder to watcher", "error", err)
 		} else {
-			log.Printf("Watching quicksave folder for changes")
+			slog.Info("Watching quicksave folder for changes")
 		}
 	}
 	
 	// List existing save folders for debugging
-	log.Printf("")
-	log.Printf("Current save folders:")
+	slog.Info("Current save folders:")
 	files, err := os.ReadDir(savesPath)
 	if err != nil {
-		log.Printf("Warning: Could not read folder contents: %v", err)
+		slog.Warn("Could not read folder contents", "error", err)
 	} else {
 		if len(files) == 0 {
-			log.Printf("  (folder is empty)")
+			slog.Info("  (folder is empty)")
 		} else {
 			for _, file := range files {
 				if file.IsDir() && file.Name() != backupFolderName {
-					log.Printf("  - %s (folder)", file.Name())
+					slog.Info("  - " + file.Name() + " (folder)")
 				}
 			}
 		}
 	}
-	log.Printf("")
-	log.Printf("File watcher initialized. Waiting for save file changes...")
-	log.Printf("Press Ctrl+C to exit")
-	if config.VerboseLogging {
-		log.Printf("(Verbose logging enabled: All file events will be logged)")
-	}
+	slog.Info("File watcher initialized. Waiting for save file changes...")
+	slog.Info("Press Ctrl+C to exit, or type 'h' and Enter for alarm commands")
+	slog.Debug("Debug logging enabled: all file events will be logged")
 	
 	// Initialize alarm timer on startup
-	// If quicksave folder exists, check its modification time to determine last save time
-	if info, err := os.Stat(quicksaveFolder); err == nil {
-		// Quicksave folder exists, use its modification time as last save time
-		reminder.lastSaveTime = info.ModTime()
-		log.Printf("Quicksave folder found, last modified: %s", reminder.lastSaveTime.Format("2006-01-02 15:04:05"))
-		reminder.startAlarmTimer()
+	// The first alarm is due alarm_interval after the last save, going by the
+	// newest file in the quicksave slot and the newest backup
+	if lastSave, source := reminder.detectLastSave(); !lastSave.IsZero() {
+		reminder.lastSaveTime = lastSave
+		reminder.lastSaveFound = true
+		slog.Info("Last save found", "time", lastSave.Format("2006-01-02 15:04:05"), "from", source)
 	} else {
-		// No quicksave folder yet, start timer from now
+		// No saves or backups yet, start timer from now
 		reminder.lastSaveTime = time.Now()
-		log.Printf("No quicksave folder yet, alarm timer will start from now")
-		reminder.startAlarmTimer()
+		slog.Info("No quicksave yet, alarm timer will start from now")
+	}
+	
+	// Carry on where the last run left off, e.g. after a crash or reboot
+	pendingBackup := false
+	reminder.statePath = resolveStatePath(config)
+	if reminder.statePath != "" {
+		if state, ok, err := loadState(reminder.statePath); err != nil {
+			slog.Warn("Could not resume saved state", "error", err)
+		} else if ok {
+			pendingBackup = reminder.restoreState(state)
+			reminder.lastSaveFound = reminder.lastSaveFound || !state.LastSave.IsZero()
+		}
+	}
+	reminder.resumeAlarmTimer()
+	if reminder.statePath != "" {
+		reminder.saveState()
+		go reminder.runStateSaver()
 	}
 	
 	// Set up signal handling for graceful shutdown
@@ -197,13 +438,93 @@ func main() {
 	// Process events in a goroutine
 	go reminder.processEvents()
 	
-	// Wait for interrupt signal
-	<-sigChan
-	log.Printf("")
-	log.Printf("Shutting down...")
+	// A quicksave the last run saw but didn't get to back up
+	if pendingBackup {
+		slog.Info("Quicksave changed before the last exit and wasn't backed up yet, backing it up now")
+		go reminder.processQuicksave(quicksaveFolder)
+	}
+	
+	// Pause alarms while the game isn't running. Play sessions in the history
+	// follow the game; without game detection they follow the application.
+	if config.PauseWhenGameClosed {
+		go reminder.watchGameProcess(newGameDetector(config.GameProcessNames))
+	} else {
+		reminder.startSession()
+	}
+	
+	// Session mode: only run the reminder during the configured windows
+	if len(reminder.sessionWindows) > 0 {
+		go reminder.watchSessionWindows()
+	}
+	
+	// Pause alarms while the player is away from the keyboard
+	if config.IdlePauseAfter != "" {
+		if idlePauseAfter, err := time.ParseDuration(config.IdlePauseAfter); err != nil || idlePauseAfter <= 0 {
+			slog.Warn("Invalid idle_pause_after in config, idle detection disabled", "error", err)
+		} else {
+			go reminder.watchIdle(systemIdleProvider{}, idlePauseAfter, idleCheckInterval)
+		}
+	}
+	
+	// Accept snooze/acknowledge commands from the console and the control socket.
+	// The TUI reads the keyboard itself.
+	if !options.tui {
+		go reminder.readConsoleCommands()
+	}
+	if config.ControlAddress != "" {
+		if err := reminder.startControlServer(config.ControlAddress); err != nil {
+			slog.Warn("Control socket disabled", "error", err)
+		}
+	}
+	
+	// Back up characters as the game writes them, e.g. in multiplayer where
+	// the character file goes to the vault rather than the quicksave
+	if config.VaultBackup.Enabled {
+		if err := reminder.watchVaults(); err != nil {
+			slog.Warn("Vault backups disabled", "error", err)
+		}
+	}
+	
+	// Back up the vaults and the rest of the NWN2 user folder now and then
+	if config.FullSnapshot.Interval != "" {
+		if interval, err := time.ParseDuration(config.FullSnapshot.Interval); err != nil || interval <= 0 {
+			slog.Warn("Invalid full_snapshot interval in config, scheduled full snapshots disabled", "interval", config.FullSnapshot.Interval, "error", err)
+		} else {
+			go reminder.runFullSnapshots(interval)
+		}
+	}
+	
+	// Make a checkpoint backup from anywhere, including inside the game
+	if config.CheckpointHotkey != "" {
+		if err := reminder.registerCheckpointHotkey(config.CheckpointHotkey); err != nil {
+			slog.Warn("Checkpoint hotkey disabled", "hotkey", config.CheckpointHotkey, "error", err)
+		}
+	}
+	
+	// Wait for interrupt signal (or Quit in the tray menu or TUI)
+	switch {
+	case options.tray:
+		reminder.runTray(sigChan)
+	case options.tui:
+		if err := reminder.runTUI(sigChan); err != nil {
+			slog.Warn("Terminal interface unavailable, running in the console", "error", err)
+			go reminder.readConsoleCommands()
+			<-sigChan
+		}
+	default:
+		<-sigChan
+	}
+	slog.Info("Shutting down...")
+	if config.FullSnapshot.OnShutdown {
+		if _, err := reminder.takeFullSnapshot(false); err != nil {
+			slog.Error("Full snapshot failed", "error", err)
+		}
+	}
 	reminder.cleanup()
-	log.Printf("Goodbye!")
-	pauseBeforeExit("")
+	slog.Info("Goodbye!")
+	if !options.tray {
+		pauseBeforeExit("")
+	}
 }
 
 // getConfigPath returns the path to the config file (in the same directory as the executable)
@@ -228,6 +549,15 @@ func getExecutableDir() string {
 	return filepath.Dir(exePath)
 }
 
+// resolveExePath resolves a relative path from the config (log, state, key
+// file, ...) against the executable directory
+func resolveExePath(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(getExecutableDir(), path)
+}
+
 // resolveSoundPath resolves the sound file path, supporting both absolute and relative paths
 // Relative paths are resolved relative to the executable directory
 func (sr *SaveReminder) resolveSoundPath(path string) string {
@@ -271,7 +601,7 @@ func loadConfig() (Config, error) {
 		if err := saveConfig(defaultConfig); err != nil {
 			return defaultConfig, fmt.Errorf("failed to create default config file: %v", err)
 		}
-		log.Printf("Created default config file: %s", configPath)
+		slog.Info("Created default config file", "path", configPath)
 		return defaultConfig, nil
 	}
 	
@@ -296,8 +626,59 @@ func loadConfig() (Config, error) {
 	}
 	if config.RepeatInterval == "" {
 		config.RepeatInterval = "5m"
+	} else if d, err := time.ParseDuration(config.RepeatInterval); err != nil || d <= 0 {
+		slog.Warn("Invalid repeat_interval in config, using 5m", "repeat_interval", config.RepeatInterval, "error", err)
+		config.RepeatInterval = "5m"
+	}
+	if config.SnoozeDuration == "" {
+		config.SnoozeDuration = "10m"
+	}
+	switch config.AlarmMode {
+	case "":
+		config.AlarmMode = alarmModeSound
+	case alarmModeSound, alarmModeSpeech, alarmModeSoundSpeech:
+	default:
+		slog.Warn("Unknown alarm_mode in config, using "+alarmModeSound, "alarm_mode", config.AlarmMode)
+		config.AlarmMode = alarmModeSound
+	}
+	if config.SpeechMessage == "" {
+		config.SpeechMessage = defaultSpeechMessage
+	}
+	switch config.BackupMode {
+	case "":
+		config.BackupMode = backupModeFolders
+	case backupModeFolders, backupModeGit, backupModeBoth:
+	default:
+		slog.Warn("Unknown backup_mode in config, using "+backupModeFolders, "backup_mode", config.BackupMode)
+		config.BackupMode = backupModeFolders
+	}
+	if config.FullSnapshot.Paths == nil {
+		config.FullSnapshot.Paths = defaultFullSnapshotPaths()
+	}
+	if config.VaultBackup.Paths == nil {
+		config.VaultBackup.Paths = defaultVaultPaths()
+	}
+	if config.TTSBackend == "" {
+		config.TTSBackend = "auto"
+	}
+	if len(config.GameProcessNames) == 0 {
+		config.GameProcessNames = defaultGameProcessNames()
+	}
+	if config.GameCheckInterval == "" {
+		config.GameCheckInterval = "10s"
+	}
+	// AlarmSoundFile can be empty (uses the built-in tone)
+	if len(config.AlarmTone.Pattern) == 0 {
+		config.AlarmTone = defaultTone()
+	}
+	if config.AlarmTone.Repeat > maxToneRepeat {
+		slog.Warn("alarm_tone repeat in config is too high, using the maximum", "repeat", config.AlarmTone.Repeat, "max", maxToneRepeat)
+		config.AlarmTone.Repeat = maxToneRepeat
+	}
+	if _, err := parseTone(config.AlarmTone); err != nil {
+		slog.Warn("Invalid alarm_tone in config, using the default tone", "error", err)
+		config.AlarmTone = defaultTone()
 	}
-	// AlarmSoundFile can be empty (uses system beep)
 	// Validate alarm volume (0-100)
 	if config.AlarmVolume < 0 {
 		config.AlarmVolume = 0
@@ -309,27 +690,164 @@ func loadConfig() (Config, error) {
 		// Only set to 100 if it's actually 0 and no sound file (might be intentional mute)
 		// But if it's 0 in JSON, it means user set it, so keep it
 	}
-	// VerboseLogging defaults to false if not set
+	if config.LogLevel == "" {
+		config.LogLevel = "info"
+		if config.VerboseLogging {
+			config.LogLevel = "debug"
+		}
+	}
+	if config.LogFile == "" {
+		config.LogFile = defaultLogFile
+	}
+	if config.LogMaxSizeMB <= 0 {
+		config.LogMaxSizeMB = 10
+	}
+	if config.LogMaxFiles <= 0 {
+		config.LogMaxFiles = 5
+	}
+	if config.HistoryFile == "" {
+		config.HistoryFile = defaultHistoryFile
+	}
+	if config.AdaptiveMinInterval == "" {
+		config.AdaptiveMinInterval = "3m"
+	}
+	if config.AdaptiveMaxInterval == "" {
+		config.AdaptiveMaxInterval = "15m"
+	}
 	
 	return config, nil
 }
 
 // printConfig prints the current configuration in a readable format
 func printConfig(config Config) {
-	log.Printf("")
-	log.Printf("=== Configuration ===")
-	log.Printf("Alarm Interval:    %s", config.AlarmInterval)
-	log.Printf("Debounce Delay:   %s", config.DebounceDelay)
-	log.Printf("Repeat Interval:   %s", config.RepeatInterval)
+	slog.Info("=== Configuration ===")
+	slog.Info(fmt.Sprintf("Alarm Interval:    %s", config.AlarmInterval))
+	slog.Info(fmt.Sprintf("Debounce Delay:   %s", config.DebounceDelay))
+	if config.AdaptiveInterval {
+		slog.Info(fmt.Sprintf("Adaptive Interval: %s-%s", config.AdaptiveMinInterval, config.AdaptiveMaxInterval))
+	}
+	slog.Info(fmt.Sprintf("Repeat Interval:   %s", config.RepeatInterval))
 	if config.AlarmSoundFile != "" {
-		log.Printf("Alarm Sound File: %s", config.AlarmSoundFile)
+		slog.Info(fmt.Sprintf("Alarm Sound File: %s", config.AlarmSoundFile))
+	} else {
+		slog.Info(fmt.Sprintf("Alarm Sound File: (built-in tone, %d notes x%d)", len(config.AlarmTone.Pattern), max(config.AlarmTone.Repeat, 1)))
+	}
+	slog.Info(fmt.Sprintf("Alarm Volume:      %d%%", config.AlarmVolume))
+	slog.Info(fmt.Sprintf("Normalize Loudness: %v", config.NormalizeLoudness))
+	slog.Info(fmt.Sprintf("Alarm Playback:    fade in %s, loop %v, max %s", config.AlarmFadeIn, config.AlarmLoop, config.AlarmMaxDuration))
+	if config.AudioDevice != "" {
+		slog.Info(fmt.Sprintf("Audio Device:      %s", config.AudioDevice))
+	} else {
+		slog.Info("Audio Device:      (system default)")
+	}
+	slog.Info(fmt.Sprintf("Alarm Mode:        %s", config.AlarmMode))
+	if config.AlarmMode != alarmModeSound {
+		slog.Info(fmt.Sprintf("Speech Message:    %s (via %s)", config.SpeechMessage, config.TTSBackend))
+	}
+	slog.Info(fmt.Sprintf("Log Level:         %s", config.LogLevel))
+	if config.LogFile != "none" {
+		slog.Info(fmt.Sprintf("Log File:          %s (rotated at %d MB, %d kept)", resolveExePath(config.LogFile), config.LogMaxSizeMB, config.LogMaxFiles))
+	} else {
+		slog.Info("Log File:          (disabled)")
+	}
+	if path := resolveHistoryPath(config); path != "" {
+		slog.Info(fmt.Sprintf("History File:      %s", path))
+	} else {
+		slog.Info("History File:      (disabled)")
+	}
+	if path := resolveStatePath(config); path != "" {
+		slog.Info(fmt.Sprintf("State File:        %s", path))
+	} else {
+		slog.Info("State File:        (disabled)")
+	}
+	if len(config.ReplicationTargets) > 0 {
+		slog.Info(fmt.Sprintf("Replicate To:      %s", strings.Join(resolveReplicationTargets(config), ", ")))
+	}
+	slog.Info(fmt.Sprintf("Backup Mode:       %s", config.BackupMode))
+	if config.S3.Endpoint != "" {
+		slog.Info(fmt.Sprintf("S3 Bucket:         %s/%s/%s", strings.TrimSuffix(config.S3.Endpoint, "/"), config.S3.Bucket, config.S3.Prefix))
+	}
+	if config.Encryption.KeyFile != "" {
+		slog.Info(fmt.Sprintf("Encryption:        key file %s", resolveExePath(config.Encryption.KeyFile)))
+	} else if config.Encryption.Passphrase != "" || os.Getenv(passphraseEnv) != "" {
+		slog.Info("Encryption:        passphrase")
+	}
+	if config.FullSnapshot.Interval != "" || config.FullSnapshot.OnShutdown {
+		when := []string{}
+		if config.FullSnapshot.Interval != "" {
+			when = append(when, "every "+config.FullSnapshot.Interval)
+		}
+		if config.FullSnapshot.OnShutdown {
+			when = append(when, "on shutdown")
+		}
+		slog.Info(fmt.Sprintf("Full Snapshots:    %s (%s)", strings.Join(when, " and "), strings.Join(config.FullSnapshot.Paths, ", ")))
+	}
+	if config.VaultBackup.Enabled {
+		slog.Info(fmt.Sprintf("Vault Backups:     %s (counts as save: %v)", strings.Join(config.VaultBackup.Paths, ", "), config.VaultBackup.CountsAsSave))
+	}
+	if config.Retention.enabled() {
+		slog.Info(fmt.Sprintf("Retention:         keep last %d, max age %q", config.Retention.KeepLast, config.Retention.MaxAge))
+	}
+	slog.Info(fmt.Sprintf("Snooze Duration:   %s", config.SnoozeDuration))
+	if config.ControlAddress != "" {
+		slog.Info(fmt.Sprintf("Control Address:   %s", config.ControlAddress))
+	} else {
+		slog.Info("Control Address:   (disabled)")
+	}
+	if config.CheckpointHotkey != "" {
+		slog.Info(fmt.Sprintf("Checkpoint Hotkey: %s", config.CheckpointHotkey))
+	}
+	if config.PauseWhenGameClosed {
+		slog.Info(fmt.Sprintf("Pause When Closed: %s (checked every %s)", strings.Join(config.GameProcessNames, ", "), config.GameCheckInterval))
 	} else {
-		log.Printf("Alarm Sound File: (system beep)")
+		slog.Info("Pause When Closed: false")
+	}
+	if config.IdlePauseAfter != "" {
+		slog.Info(fmt.Sprintf("Pause When Idle:   after %s without input", config.IdlePauseAfter))
+	} else {
+		slog.Info("Pause When Idle:   (disabled)")
+	}
+	printSchedule("Alarm Schedule:   ", config.AlarmSchedule, "(any time)")
+	printSchedule("Session Windows:  ", config.SessionWindows, "(always)")
+	slog.Info(fmt.Sprintf("Tray Mode:         %v", config.TrayMode))
+	if len(config.AlarmEscalation) > 0 {
+		slog.Info("Alarm Escalation:")
+		for _, step := range config.AlarmEscalation {
+			sound := step.SoundFile
+			if sound == "" {
+				sound = "(alarm sound)"
+			}
+			repeat := step.Repeat
+			if repeat == "" {
+				repeat = config.RepeatInterval
+			}
+			volume := "(alarm volume)"
+			if step.Volume != nil {
+				volume = fmt.Sprintf("%d%%", *step.Volume)
+			}
+			slog.Info(fmt.Sprintf("  after %s: %s, volume %s, every %s", step.After, sound, volume, repeat))
+		}
+	}
+	slog.Info("===================")
+}
+
+// printSchedule prints schedule windows, one per line
+func printSchedule(label string, windows []ScheduleWindow, empty string) {
+	if len(windows) == 0 {
+		slog.Info(fmt.Sprintf("%s %s", label, empty))
+		return
+	}
+	for i, w := range windows {
+		days := "every day"
+		if len(w.Days) > 0 {
+			days = strings.Join(w.Days, ", ")
+		}
+		if i == 0 {
+			slog.Info(fmt.Sprintf("%s %s-%s (%s)", label, w.Start, w.End, days))
+		} else {
+			slog.Info(fmt.Sprintf("%s %s-%s (%s)", strings.Repeat(" ", len(label)), w.Start, w.End, days))
+		}
 	}
-	log.Printf("Alarm Volume:      %d%%", config.AlarmVolume)
-	log.Printf("Verbose Logging:   %v", config.VerboseLogging)
-	log.Printf("===================")
-	log.Printf("")
 }
 
 // saveConfig saves the configuration to a JSON file
@@ -350,6 +868,21 @@ func saveConfig(config Config) error {
 	return nil
 }
 
+// getSavesFolder returns the Documents folder and the multiplayer saves folder in it
+func getSavesFolder() (documentsPath, savesPath string) {
+	// Get the Documents folder path (handles custom locations)
+	documentsPath, err := getDocumentsFolder()
+	if err != nil {
+		slog.Warn("Could not determine Documents folder, using default", "error", err)
+		// Fallback to standard location
+		documentsPath = filepath.Join(os.Getenv("USERPROFILE"), "Documents")
+	}
+	
+	// Get the saves folder path
+	savesPath = filepath.Join(documentsPath, "Neverwinter Nights 2", "saves", "multiplayer")
+	return documentsPath, savesPath
+}
+
 // getDocumentsFolder gets the actual Documents folder path on Windows
 // This handles cases where the Documents folder has been moved to a custom location
 func getDocumentsFolder() (string, error) {
@@ -379,6 +912,28 @@ func getDocumentsFolder() (string, error) {
 	return path, nil
 }
 
+// runOptions are the command-line flags of the run command
+type runOptions struct {
+	tray bool // Show a system tray icon instead of using the console
+	tui  bool // Show the full-screen terminal interface
+}
+
+// parseRunOptions parses the run command's flags; config values are the defaults
+func parseRunOptions(args []string, config Config) (runOptions, error) {
+	var options runOptions
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	flags.BoolVar(&options.tray, "tray", config.TrayMode, "run as a system tray icon")
+	flags.BoolVar(&options.tui, "tui", false, "show a full-screen terminal interface")
+	if err := flags.Parse(args); err != nil {
+		return options, err
+	}
+	if options.tui {
+		// --tui wins over tray_mode from the config
+		options.tray = false
+	}
+	return options, nil
+}
+
 // pauseBeforeExit pauses execution so the user can read error messages
 // when running as a double-clickable executable on Windows
 func pauseBeforeExit(message string) {
@@ -405,35 +960,42 @@ func (sr *SaveReminder) processEvents() {
 				return
 			}
 			
-			// Log all file events for debugging (only if verbose)
-			if sr.verbose {
-				log.Printf("File event detected: %s (op: %s)", event.Name, event.Op.String())
-			}
+			// Log all file events for debugging
+			slog.Debug("File event detected", "file", event.Name, "op", event.Op.String())
 			
 			// Check if this event is related to the quicksave folder
 			if sr.isQuicksaveRelated(event.Name) {
-				if sr.verbose {
-					log.Printf("Quicksave-related change detected: %s", event.Name)
-				}
+				slog.Debug("Quicksave-related change detected", "file", event.Name)
 				sr.handleQuicksaveChange(event)
 			} else {
-				if sr.verbose {
-					log.Printf("Ignored (not quicksave): %s", filepath.Base(event.Name))
-				}
+				slog.Debug("Ignored (not quicksave)", "file", filepath.Base(event.Name))
 			}
 			
 		case err, ok := <-sr.watcher.Errors:
 			if !ok {
 				return
 			}
-			log.Printf("Watcher error: %v", err)
+			slog.Error("Watcher error", "error", err)
 		}
 	}
 }
 
 func (sr *SaveReminder) cleanup() {
-	// Stop all timers
+	// Remember the state for the next start
+	sr.mu.Lock()
+	sr.stopped = true
+	sr.mu.Unlock()
+	sr.saveState()
+	
+	// Stop background monitors and all timers
+	close(sr.done)
 	sr.resetAlarmTimers()
+	sr.endSession()
+	
+	// Stop accepting control commands
+	if sr.controlListener != nil {
+		sr.controlListener.Close()
+	}
 	
 	// Close watcher
 	if sr.watcher != nil {
@@ -466,9 +1028,9 @@ func (sr *SaveReminder) handleQuicksaveChange(event fsnotify.Event) {
 		if event.Op&fsnotify.Create != 0 {
 			quicksaveFolder := filepath.Join(sr.savesPath, quicksaveName)
 			if event.Name == quicksaveFolder {
-				log.Printf("Quicksave folder created, adding to watcher...")
+				slog.Info("Quicksave folder created, adding to watcher...")
 				if err := sr.watcher.Add(quicksaveFolder); err != nil {
-					log.Printf("Warning: Failed to add quicksave folder to watcher: %v", err)
+					slog.Warn("Failed to add quicksave folder to watcher", "error", err)
 				}
 			}
 		}
@@ -480,6 +1042,15 @@ func (sr *SaveReminder) handleQuicksaveChange(event fsnotify.Event) {
 		return
 	}
 	
+	// Ignore our own writes while a backup is being restored
+	sr.mu.Lock()
+	restoring := time.Now().Before(sr.ignoreEventsUntil)
+	sr.mu.Unlock()
+	if restoring {
+		slog.Debug("Ignored (restore in progress)", "file", filepath.Base(event.Name))
+		return
+	}
+	
 	// Cancel existing debounce timer if any
 	if sr.debounceTimer != nil {
 		sr.debounceTimer.Stop()
@@ -488,7 +1059,7 @@ func (sr *SaveReminder) handleQuicksaveChange(event fsnotify.Event) {
 	// Parse debounce delay from config
 	debounceDelay, err := time.ParseDuration(sr.config.DebounceDelay)
 	if err != nil {
-		log.Printf("Warning: Invalid debounce_delay in config, using 3s: %v", err)
+		slog.Warn("Invalid debounce_delay in config, using 3s", "error", err)
 		debounceDelay = 3 * time.Second
 	}
 	
@@ -497,50 +1068,135 @@ func (sr *SaveReminder) handleQuicksaveChange(event fsnotify.Event) {
 		sr.processQuicksave(filepath.Join(sr.savesPath, quicksaveName))
 	})
 	
-	log.Printf("Detected change in quicksave folder, waiting %v before processing...", debounceDelay)
+	// Remember the change, so it's still backed up if the application stops before then
+	sr.mu.Lock()
+	firstChange := sr.pendingBackup.IsZero()
+	if firstChange {
+		sr.pendingBackup = time.Now()
+	}
+	sr.mu.Unlock()
+	if firstChange {
+		sr.saveState()
+	}
+	
+	slog.Info("Detected change in quicksave folder, waiting before processing", "delay", debounceDelay)
 }
 
 func (sr *SaveReminder) processQuicksave(quicksaveFolderPath string) {
-	log.Printf("Processing quicksave folder: %s", quicksaveFolderPath)
+	slog.Info("Processing quicksave folder", "path", quicksaveFolderPath)
+	sr.mu.Lock()
+	sr.pendingBackup = time.Time{}
+	sr.mu.Unlock()
 	
 	// Check if folder exists
 	if _, err := os.Stat(quicksaveFolderPath); os.IsNotExist(err) {
-		log.Printf("Quicksave folder no longer exists, skipping backup")
+		slog.Info("Quicksave folder no longer exists, skipping backup")
 		return
 	}
 	
 	// Create backup of the entire folder
-	if err := sr.createBackup(quicksaveFolderPath); err != nil {
-		log.Printf("Error creating backup: %v", err)
+	backupName, backupPath, err := sr.createBackup(quicksaveFolderPath)
+	if err != nil {
+		slog.Error("Backup failed", "error", err)
+		sr.recordSaveEvent(saveEvent{Time: time.Now(), Err: err})
+		sr.recordHistory(historyEvent{Type: historyBackupFailed, Error: err.Error()})
+		sr.saveState()
 		return
 	}
 	
-	// Reset alarm timers
-	sr.resetAlarmTimers()
-	
-	// Update last save time
-	sr.lastSaveTime = time.Now()
-	log.Printf("Save processed successfully. Alarm timer reset.")
+	sr.markSaved(backupName, backupPath)
+	slog.Info("Save processed successfully. Alarm timer reset.")
+}
+
+// markSaved records a save that was backed up and restarts the alarm timer
+func (sr *SaveReminder) markSaved(backupName, backupPath string) {
+	// Reset alarm timers and update last save time. Pauses move lastSaveTime
+	// forward, so the interval only counts time the player was actually playing.
+	sr.mu.Lock()
+	sr.stopAlarmLocked()
+	now := time.Now()
+	interval := now.Sub(sr.lastSaveTime)
+	sameSession := sr.savedThisSession
+	sr.savedThisSession = true
+	sr.lastSaveTime = now
+	sr.lastAlarmStep = 0
+	sr.mu.Unlock()
+	sr.recordSaveEvent(saveEvent{Time: now, Backup: backupName})
+	if !sameSession {
+		interval = 0
+	}
+	sr.recordSaveHistory(backupName, backupPath, interval)
+	if interval > 0 {
+		sr.recordSaveInterval(interval)
+	}
 	
 	// Start new alarm timer
 	sr.startAlarmTimer()
+	sr.saveState()
 }
 
-func (sr *SaveReminder) createBackup(quicksaveFolderPath string) error {
-	// Create timestamp folder
-	timestamp := time.Now().Format("2006-01-02_15-04-05")
-	backupFolderName := fmt.Sprintf("%s - %s", timestamp, quicksaveName)
-	destFolder := filepath.Join(sr.backupsPath, backupFolderName)
+// createBackup backs up the quicksave as set by backup_mode. It returns the
+// backup's name (the folder name, or the commit in git-only mode) and the
+// backup folder's path (empty in git-only mode).
+func (sr *SaveReminder) createBackup(quicksaveFolderPath string) (name, path string, err error) {
+	if sr.gitBackup != nil {
+		hash, err := sr.gitBackup.commit(quicksaveFolderPath, quicksaveName, time.Now())
+		if err != nil {
+			return "", "", fmt.Errorf("error committing to git repository: %v", err)
+		}
+		name = "git " + hash.String()[:8]
+		slog.Info("Save committed to git repository", "commit", hash.String()[:8], "path", sr.gitBackup.path)
+	}
+	if !backupModeIncludes(sr.config, backupModeFolders) {
+		return name, "", nil
+	}
+	
+	path, err = sr.createLabeledBackup(quicksaveFolderPath, quicksaveName, backupMetadata{})
+	if err != nil {
+		return "", path, err
+	}
+	sr.pruneBackups()
+	return filepath.Base(path), path, nil
+}
+
+// createLabeledBackup copies a folder into a new "timestamp - label" backup folder,
+// with the given metadata, and returns the backup folder's path. A backup that
+// fails is removed again; backups made before it are never touched.
+func (sr *SaveReminder) createLabeledBackup(srcFolder, label string, meta backupMetadata) (string, error) {
+	// Claim a timestamp folder, copying into it under a partial name
+	backupFolderName, partial, err := sr.claimBackupName(fmt.Sprintf("%s - %s", time.Now().Format(backupTimestampFormat), label))
+	if err != nil {
+		return "", err
+	}
 	
-	if err := os.MkdirAll(destFolder, 0755); err != nil {
-		return fmt.Errorf("error creating backup folder: %v", err)
+	// Copy the entire folder recursively
+	if err := copyDirectory(srcFolder, partial); err != nil {
+		os.RemoveAll(partial)
+		return "", err
+	}
+	if meta != (backupMetadata{}) {
+		if err := writeBackupMetadata(partial, meta); err != nil {
+			os.RemoveAll(partial)
+			return "", err
+		}
 	}
+	destFolder := filepath.Join(sr.backupsPath, backupFolderName)
+	if err := os.Rename(partial, destFolder); err != nil {
+		os.RemoveAll(partial)
+		return "", fmt.Errorf("error finishing backup: %v", err)
+	}
+	slog.Info("Backup created", "path", destFolder)
+	sr.backupAdded(destFolder)
 	
-	// Copy the entire quicksave folder recursively
-	return sr.copyDirectory(quicksaveFolderPath, destFolder)
+	// Send a copy to the replication targets in the background
+	if sr.replicator != nil {
+		sr.replicator.enqueue(backupFolderName)
+	}
+	return destFolder, nil
 }
 
-func (sr *SaveReminder) copyDirectory(src, dst string) error {
+// copyDirectory copies a folder and everything in it
+func copyDirectory(src, dst string) error {
 	// Get source info
 	srcInfo, err := os.Stat(src)
 	if err != nil {
@@ -565,22 +1221,21 @@ func (sr *SaveReminder) copyDirectory(src, dst string) error {
 		
 		if entry.IsDir() {
 			// Recursively copy subdirectories
-			if err := sr.copyDirectory(srcPath, dstPath); err != nil {
+			if err := copyDirectory(srcPath, dstPath); err != nil {
 				return err
 			}
 		} else {
 			// Copy file
-			if err := sr.copyFile(srcPath, dstPath); err != nil {
+			if err := copyFile(srcPath, dstPath); err != nil {
 				return err
 			}
 		}
 	}
 	
-	log.Printf("Backup created: %s", dst)
 	return nil
 }
 
-func (sr *SaveReminder) copyFile(src, dst string) error {
+func copyFile(src, dst string) error {
 	// Read source file
 	data, err := os.ReadFile(src)
 	if err != nil {
@@ -596,88 +1251,269 @@ func (sr *SaveReminder) copyFile(src, dst string) error {
 }
 
 func (sr *SaveReminder) resetAlarmTimers() {
-	// Stop and clear existing timers
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	sr.stopAlarmLocked()
+}
+
+// stopAlarmLocked stops and clears the pending alarm timer. sr.mu must be held.
+func (sr *SaveReminder) stopAlarmLocked() {
+	// Bump the generation so a timer that already fired becomes a no-op
+	sr.alarmGen++
 	if sr.alarmTimer != nil {
 		sr.alarmTimer.Stop()
 		sr.alarmTimer = nil
 	}
-	if sr.repeatTimer != nil {
-		sr.repeatTimer.Stop()
-		sr.repeatTimer = nil
-	}
+	sr.nextAlarmAt = time.Time{}
 	sr.alarmActive = false
+	
+	// Cut off an alarm that is still playing
+	sr.stopPlaybackLocked()
 }
 
-func (sr *SaveReminder) startAlarmTimer() {
-	// Parse alarm interval from config
+// scheduleAlarmLocked replaces any pending alarm with one that fires after delay. sr.mu must be held.
+func (sr *SaveReminder) scheduleAlarmLocked(delay time.Duration) {
+	sr.alarmGen++
+	gen := sr.alarmGen
+	if sr.alarmTimer != nil {
+		sr.alarmTimer.Stop()
+	}
+	sr.nextAlarmAt = time.Now().Add(delay)
+	sr.alarmTimer = time.AfterFunc(delay, func() {
+		sr.onAlarmTimer(gen)
+	})
+}
+
+// alarmInterval returns the time before the first alarm: the learned interval
+// in adaptive mode, otherwise alarm_interval
+func (sr *SaveReminder) alarmInterval() time.Duration {
+	sr.mu.Lock()
+	adaptive := sr.adaptiveDelay
+	sr.mu.Unlock()
+	if adaptive > 0 {
+		return adaptive
+	}
+	return sr.configuredAlarmInterval()
+}
+
+// configuredAlarmInterval returns alarm_interval from the config
+func (sr *SaveReminder) configuredAlarmInterval() time.Duration {
 	alarmInterval, err := time.ParseDuration(sr.config.AlarmInterval)
 	if err != nil {
-		log.Printf("Warning: Invalid alarm_interval in config, using 5m: %v", err)
+		slog.Warn("Invalid alarm_interval in config, using 5m", "error", err)
 		alarmInterval = 5 * time.Minute
 	}
+	return alarmInterval
+}
+
+func (sr *SaveReminder) startAlarmTimer() {
+	alarmInterval := sr.alarmInterval()
 	
 	// Start the initial alarm timer
-	sr.alarmTimer = time.AfterFunc(alarmInterval, func() {
-		sr.triggerAlarm()
-		sr.startRepeatAlarm()
-	})
+	sr.mu.Lock()
+	sr.acknowledged = false
+	if len(sr.paused) > 0 {
+		reasons := sr.pauseReasonsLocked()
+		sr.mu.Unlock()
+		slog.Info("Alarm timer not started while alarms are paused", "reasons", reasons)
+		return
+	}
+	sr.scheduleAlarmLocked(alarmInterval)
+	sr.mu.Unlock()
 	
-	log.Printf("Alarm timer started. Will alert in %v if no new save is made.", alarmInterval)
+	slog.Info("Alarm timer started", "alarm_in", alarmInterval)
 }
 
-func (sr *SaveReminder) startRepeatAlarm() {
-	sr.alarmActive = true
+// resumeAlarmTimer schedules the next alarm counting from the last save
+// rather than from now. An overdue alarm sounds straight away, at the
+// escalation step for the time since the last save.
+func (sr *SaveReminder) resumeAlarmTimer() {
+	alarmInterval := sr.alarmInterval()
 	
-	// Parse repeat interval from config
-	repeatInterval, err := time.ParseDuration(sr.config.RepeatInterval)
-	if err != nil {
-		log.Printf("Warning: Invalid repeat_interval in config, using 5m: %v", err)
-		repeatInterval = 5 * time.Minute
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	if sr.acknowledged {
+		slog.Info("Alarm remains acknowledged until the next save")
+		return
 	}
+	delay := alarmInterval - time.Since(sr.lastSaveTime)
+	if delay < 0 {
+		delay = 0
+	}
+	sr.scheduleAlarmLocked(delay)
+	slog.Info("Alarm timer resumed", "alarm_in", delay.Round(time.Second))
+}
+
+// pauseAlarms stops the alarm timer until every pause reason has been lifted
+func (sr *SaveReminder) pauseAlarms(reason string) {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
 	
-	// Start repeating alarm
-	sr.repeatTimer = time.NewTicker(repeatInterval)
-	go func() {
-		for range sr.repeatTimer.C {
-			sr.triggerAlarm()
-		}
-	}()
+	if _, ok := sr.paused[reason]; ok {
+		return
+	}
+	sr.paused[reason] = time.Now()
+	sr.stopAlarmLocked()
+	slog.Info("Alarms paused", "reason", reason)
 }
 
-func (sr *SaveReminder) triggerAlarm() {
-	log.Printf("*** ALARM: Time to save! It's been %v since last save. ***", time.Since(sr.lastSaveTime))
+// unpauseAlarms lifts a pause reason. With restartTimer the alarm timer starts
+// over from now (as if the game had just been saved); otherwise the paused time
+// is not counted towards the time since the last save.
+func (sr *SaveReminder) unpauseAlarms(reason string, restartTimer bool) {
+	alarmInterval := sr.alarmInterval()
+	
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	
+	since, ok := sr.paused[reason]
+	if !ok {
+		return
+	}
+	delete(sr.paused, reason)
+	if restartTimer {
+		sr.lastSaveTime = time.Now()
+	} else {
+		sr.lastSaveTime = sr.lastSaveTime.Add(time.Since(since))
+	}
 	
-	// Play alarm sound
-	sr.playAlarmSound()
+	if len(sr.paused) > 0 {
+		slog.Info("Alarm pause lifted", "reason", reason, "still_paused", sr.pauseReasonsLocked())
+		return
+	}
+	if sr.acknowledged {
+		slog.Info("Alarms unpaused, alarm remains acknowledged until next save", "reason", reason)
+		return
+	}
+	
+	delay := alarmInterval - time.Since(sr.lastSaveTime)
+	if delay < 0 {
+		delay = 0
+	}
+	sr.scheduleAlarmLocked(delay)
+	slog.Info("Alarms unpaused", "reason", reason, "next_alarm_in", delay.Round(time.Second))
 }
 
-func (sr *SaveReminder) playAlarmSound() {
-	// Check if volume is 0 (muted)
-	if sr.config.AlarmVolume == 0 {
-		if sr.verbose {
-			log.Printf("Alarm volume is 0, alarm is muted")
+// pauseReasonsLocked returns the active pause reasons as a sorted list. sr.mu must be held.
+func (sr *SaveReminder) pauseReasonsLocked() string {
+	reasons := make([]string, 0, len(sr.paused))
+	for reason := range sr.paused {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	return strings.Join(reasons, ", ")
+}
+
+// onAlarmTimer fires an alarm for the current escalation stage and schedules the next one
+func (sr *SaveReminder) onAlarmTimer(gen uint64) {
+	sr.mu.Lock()
+	if gen != sr.alarmGen || sr.acknowledged || len(sr.paused) > 0 {
+		// Superseded by a save, reset, acknowledge or pause
+		sr.mu.Unlock()
+		return
+	}
+	if !sr.snoozeUntil.IsZero() {
+		if wait := time.Until(sr.snoozeUntil); wait > 0 {
+			// Still snoozed, try again once the snooze ends
+			sr.scheduleAlarmLocked(wait)
+			sr.mu.Unlock()
+			return
 		}
+		sr.snoozeUntil = time.Time{}
+		slog.Info("Snooze ended")
+	}
+	
+	sr.alarmActive = true
+	elapsed := time.Since(sr.lastSaveTime)
+	stage := sr.stageFor(elapsed)
+	sr.lastAlarmStep = stage.index
+	delay := sr.nextAlarmDelay(elapsed)
+	sr.scheduleAlarmLocked(delay)
+	current := sr.alarmGen
+	sr.mu.Unlock()
+	sr.saveState()
+	
+	sr.triggerAlarm(current, stage, elapsed)
+	slog.Debug("Next alarm scheduled", "in", delay)
+}
+
+// triggerAlarm sounds the alarm for stage. Speech runs in the background, so
+// a long message never holds up the alarm timer; gen is the alarm generation
+// it belongs to, so it isn't spoken once the alarm has been dealt with.
+func (sr *SaveReminder) triggerAlarm(gen uint64, stage alarmStage, elapsed time.Duration) {
+	// Quiet hours: never sound outside the alarm schedule
+	if !inSchedule(sr.alarmSchedule, time.Now()) {
+		slog.Info("Alarm suppressed (outside alarm_schedule)", "since_last_save", elapsed.Round(time.Second))
 		return
 	}
 	
-	if sr.config.AlarmSoundFile != "" {
+	slog.Warn("*** ALARM: Time to save! ***", "since_last_save", elapsed.Round(time.Second))
+	sr.recordHistory(historyEvent{Type: historyAlarm, Elapsed: elapsed.Round(time.Second).String(), Step: stage.index})
+	if stage.index > 0 {
+		slog.Info("Alarm escalation", "step", stage.index, "of", len(sr.escalation))
+	}
+	slog.Info("(Type 's' to snooze or 'a' to acknowledge)")
+	
+	switch sr.config.AlarmMode {
+	case alarmModeSpeech:
+		go func() {
+			// Fall back to the alarm sound if speech isn't available
+			if !sr.speakReminder(gen, elapsed, stage.volume) {
+				sr.setPlayback(gen, sr.playAlarmSound(stage.soundFile, stage.volume))
+			}
+		}()
+	case alarmModeSoundSpeech:
+		p := sr.playAlarmSound(stage.soundFile, stage.volume)
+		sr.setPlayback(gen, p)
+		go func() {
+			if p != nil {
+				// Speak once the sound has finished, unless a save or acknowledge cut it off
+				<-p.Done()
+				if p.Stopped() {
+					return
+				}
+			}
+			sr.speakReminder(gen, elapsed, stage.volume)
+		}()
+	default:
+		sr.setPlayback(gen, sr.playAlarmSound(stage.soundFile, stage.volume))
+	}
+}
+
+// playAlarmSound starts the alarm sound and returns its playback without waiting
+// for it to finish. It returns nil if nothing is playing (muted or system beep).
+func (sr *SaveReminder) playAlarmSound(soundFile string, volume int) *playback {
+	// Check if volume is 0 (muted)
+	if volume == 0 {
+		slog.Debug("Alarm volume is 0, alarm is muted")
+		return nil
+	}
+	
+	if soundFile != "" {
 		// Try to find the audio file
 		// Supports both absolute paths and relative paths (relative to executable directory)
-		soundPath := sr.resolveSoundPath(sr.config.AlarmSoundFile)
+		soundPath := sr.resolveSoundPath(soundFile)
 		if soundPath != "" {
-			log.Printf("Playing alarm sound: %s", soundPath)
-			sr.playAudioFile(soundPath)
-			return
+			slog.Info("Playing alarm sound", "file", soundPath)
+			if p := sr.playAudioFile(soundPath, volume); p != nil {
+				return p
+			}
+			slog.Info("Falling back to the built-in alarm tone")
+		} else {
+			slog.Warn("Audio file not found in the executable or current directory, check that it exists and the path is correct", "file", soundFile)
 		}
-		log.Printf("Warning: Audio file not found: %s (searched: executable dir and current dir)", sr.config.AlarmSoundFile)
-		log.Printf("  Make sure the file exists and the path is correct")
 	}
 	
-	// Default: Use system beep
+	// Default: Built-in tone generator, played at the alarm volume
+	if p := sr.playTone(volume); p != nil {
+		return p
+	}
+	
+	// The tone can't play either (no audio device): Use system beep
 	// Note: System beep volume can't be easily controlled, but we can skip it if volume is very low
-	if sr.config.AlarmVolume < 10 {
+	if volume < 10 {
 		// Very low volume, skip beep
-		return
+		return nil
 	}
 	
 	if runtime.GOOS == "windows" {
@@ -691,86 +1527,86 @@ func (sr *SaveReminder) playAlarmSound() {
 		// Unix-like: Use console beep
 		fmt.Print("\a")
 	}
+	return nil
 }
 
-func (sr *SaveReminder) playAudioFile(filePath string) {
-	// Verify file exists
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		log.Printf("Error: Audio file does not exist: %s", filePath)
-		return
+// playAudioFile starts playing a sound file at the given volume and returns its playback.
+// It returns nil if the file couldn't be decoded or no audio device is available.
+func (sr *SaveReminder) playAudioFile(filePath string, volumeLevel int) *playback {
+	if err := sr.audio.available(); err != nil {
+		slog.Warn("Cannot play audio", "error", err)
+		return nil
 	}
 
-	// Open the audio file
-	f, err := os.Open(filePath)
+	// Decoded sounds are cached, so only the first alarm reads the file
+	snd, err := sr.audio.load(filePath)
 	if err != nil {
-		log.Printf("Error opening audio file: %v", err)
-		return
-	}
-	defer f.Close()
-
-	// Decode the audio file based on extension
-	var streamer beep.StreamSeekCloser
-	var format beep.Format
-	ext := strings.ToLower(filepath.Ext(filePath))
-
-	switch ext {
-	case ".wav":
-		streamer, format, err = wav.Decode(f)
-	case ".mp3":
-		streamer, format, err = mp3.Decode(f)
-	default:
-		log.Printf("Warning: Unsupported audio format '%s'. Supported formats: WAV, MP3", ext)
-		return
+		slog.Error("Loading audio file failed", "error", err)
+		return nil
 	}
 
+	p, err := sr.audio.startSound(snd, volumeLevel, sr.playbackOptions())
 	if err != nil {
-		log.Printf("Error decoding audio file: %v", err)
-		return
+		slog.Warn("Cannot play audio", "error", err)
+		return nil
 	}
-	defer streamer.Close()
+	slog.Debug("Audio playback started", "file", filepath.Base(filePath), "volume", volumeLevel)
+	return p
+}
 
-	// Initialize speaker if not already done
-	if !sr.speakerInitialized {
-		// Initialize with the sample rate from the audio file
-		err := speaker.Init(format.SampleRate, format.SampleRate.N(time.Second/10))
-		if err != nil {
-			log.Printf("Error initializing speaker: %v", err)
-			return
+// playbackOptions returns the fade-in, loop and duration settings for alarm sounds
+func (sr *SaveReminder) playbackOptions() playbackOptions {
+	var opts playbackOptions
+	if sr.config.AlarmFadeIn != "" {
+		if fadeIn, err := time.ParseDuration(sr.config.AlarmFadeIn); err != nil || fadeIn < 0 {
+			slog.Warn("Invalid alarm_fade_in in config, not fading in", "error", err)
+		} else {
+			opts.fadeIn = fadeIn
 		}
-		sr.speakerInitialized = true
-		if sr.verbose {
-			log.Printf("Speaker initialized (sample rate: %d Hz)", format.SampleRate)
+	}
+	if sr.config.AlarmMaxDuration != "" {
+		if maxDuration, err := time.ParseDuration(sr.config.AlarmMaxDuration); err != nil || maxDuration < 0 {
+			slog.Warn("Invalid alarm_max_duration in config, using 60s", "error", err)
+			opts.maxDuration = 60 * time.Second
+		} else {
+			opts.maxDuration = maxDuration
 		}
 	}
-
-	// Calculate volume (beep uses a logarithmic scale)
-	// Volume range: 0-100 (config) -> -5 to 0 (beep, in decibels)
-	// 0% = silent (-5 or less), 100% = full volume (0)
-	volumePercent := float64(sr.config.AlarmVolume)
-	if volumePercent < 0 {
-		volumePercent = 0
-	} else if volumePercent > 100 {
-		volumePercent = 100
+	opts.loop = sr.config.AlarmLoop
+	if opts.loop && opts.maxDuration == 0 {
+		// Never loop forever if nobody is there to acknowledge it
+		opts.maxDuration = 60 * time.Second
 	}
+	return opts
+}
 
-	// Convert percentage to decibels: 0% = -10dB (nearly silent), 100% = 0dB (full)
-	volumeDB := -10.0 + (volumePercent / 100.0 * 10.0)
-
-	// Apply volume control
-	volume := &effects.Volume{
-		Streamer: streamer,
-		Base:     2,
-		Volume:   volumeDB,
-		Silent:   volumePercent < 1, // Mute if less than 1%
+// setPlayback makes p the current alarm playback of generation gen, stopping any
+// previous one still playing. The sound is started without sr.mu held, so if a
+// save, snooze or acknowledge came in meanwhile, p is stopped instead.
+func (sr *SaveReminder) setPlayback(gen uint64, p *playback) {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	if !sr.alarmCurrentLocked(gen) {
+		if p != nil {
+			p.Stop()
+		}
+		return
 	}
+	sr.stopPlaybackLocked()
+	sr.playback = p
+}
 
-	// Play and wait for completion
-	done := make(chan bool)
-	speaker.Play(beep.Seq(volume, beep.Callback(func() {
-		done <- true
-	})))
-
-	<-done
-	log.Printf("Audio played successfully (%s, volume: %d%%)", filepath.Base(filePath), sr.config.AlarmVolume)
+// alarmCurrentLocked reports whether the alarm of generation gen should still
+// sound, i.e. it hasn't been superseded by a save or acknowledge and no snooze
+// has started since. sr.mu must be held.
+func (sr *SaveReminder) alarmCurrentLocked(gen uint64) bool {
+	return gen == sr.alarmGen && !time.Now().Before(sr.snoozeUntil)
 }
 
+// stopPlaybackLocked stops the current alarm playback, if any. sr.mu must be held.
+func (sr *SaveReminder) stopPlaybackLocked() {
+	if sr.playback != nil {
+		sr.playback.Stop()
+		sr.playback = nil
+	}
+}
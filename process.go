@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// gamePauseReason is the pause reason used while the game is not running
+const gamePauseReason = "game not running"
+
+// GameDetector reports whether Neverwinter Nights 2 is currently running
+type GameDetector interface {
+	GameRunning() (bool, error)
+}
+
+// defaultGameProcessNames returns the executable names NWN2 runs as
+func defaultGameProcessNames() []string {
+	return []string{"nwn2main.exe", "nwn2main_amd.exe"}
+}
+
+// processDetector looks for the game in the operating system's process list
+type processDetector struct {
+	names []string // Lower-case executable names to look for
+}
+
+// newGameDetector creates a detector that matches any of the given executable names
+func newGameDetector(names []string) GameDetector {
+	lower := make([]string, 0, len(names))
+	for _, name := range names {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			lower = append(lower, name)
+		}
+	}
+	return &processDetector{names: lower}
+}
+
+func (d *processDetector) GameRunning() (bool, error) {
+	switch runtime.GOOS {
+	case "windows":
+		return d.scanTasklist()
+	case "linux":
+		return d.scanProc("/proc")
+	default:
+		return false, fmt.Errorf("process detection is not supported on %s", runtime.GOOS)
+	}
+}
+
+// matches reports whether an executable name or path is one of the game's
+func (d *processDetector) matches(exe string) bool {
+	// Wine reports Windows paths, so split on both separators
+	exe = strings.ToLower(exe)
+	if i := strings.LastIndexAny(exe, `\/`); i >= 0 {
+		exe = exe[i+1:]
+	}
+	for _, name := range d.names {
+		if exe == name {
+			return true
+		}
+	}
+	return false
+}
+
+// scanTasklist checks the Windows process list
+func (d *processDetector) scanTasklist() (bool, error) {
+	output, err := exec.Command("tasklist", "/FO", "CSV", "/NH").Output()
+	if err != nil {
+		return false, fmt.Errorf("failed to run tasklist: %v", err)
+	}
+
+	records, err := csv.NewReader(bytes.NewReader(output)).ReadAll()
+	if err != nil {
+		return false, fmt.Errorf("failed to parse tasklist output: %v", err)
+	}
+	for _, record := range records {
+		if len(record) > 0 && d.matches(record[0]) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// scanProc checks /proc on Linux. Games running under Wine show up with the
+// Windows executable in their command line (e.g. "C:\...\nwn2main.exe"),
+// while comm may be truncated or name the Wine loader instead.
+func (d *processDetector) scanProc(procPath string) (bool, error) {
+	entries, err := os.ReadDir(procPath)
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s: %v", procPath, err)
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.TrimLeft(entry.Name(), "0123456789") != "" {
+			continue
+		}
+		pidPath := filepath.Join(procPath, entry.Name())
+
+		// Processes can exit while we scan, so read errors are skipped
+		if comm, err := os.ReadFile(filepath.Join(pidPath, "comm")); err == nil {
+			if d.matches(strings.TrimSpace(string(comm))) {
+				return true, nil
+			}
+		}
+		if cmdline, err := os.ReadFile(filepath.Join(pidPath, "cmdline")); err == nil {
+			// Arguments are NUL-separated; the first one is the executable
+			args := strings.Split(string(cmdline), "\x00")
+			if len(args) > 0 && d.matches(args[0]) {
+				return true, nil
+			}
+			// Wine may run the game as an argument of the loader (wine / wine64 / wine-preloader)
+			if len(args) > 1 && strings.Contains(strings.ToLower(filepath.Base(args[0])), "wine") && d.matches(args[1]) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// watchGameProcess polls the detector and pauses alarms while the game is not running.
+// When the game starts again the alarm timer restarts from zero, except on the
+// first start after a last save was found at startup: that save still counts,
+// only the time the game wasn't running doesn't.
+func (sr *SaveReminder) watchGameProcess(detector GameDetector) {
+	interval, err := time.ParseDuration(sr.config.GameCheckInterval)
+	if err != nil || interval <= 0 {
+		slog.Warn("Invalid game_check_interval in config, using 10s", "error", err)
+		interval = 10 * time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	sr.mu.Lock()
+	keepLastSave := sr.lastSaveFound
+	sr.mu.Unlock()
+
+	firstCheck := true
+	wasRunning := false
+	errorLogged := false
+	for {
+		running, err := detector.GameRunning()
+		if err != nil {
+			// Without a reliable answer, never silence the reminder
+			if !errorLogged {
+				slog.Warn("Could not check whether the game is running, alarms will not be paused", "error", err)
+				errorLogged = true
+			}
+			running = true
+		} else {
+			errorLogged = false
+		}
+
+		if firstCheck || running != wasRunning {
+			if running {
+				if !firstCheck {
+					slog.Info("Neverwinter Nights 2 started")
+				}
+				sr.startSession()
+				sr.unpauseAlarms(gamePauseReason, !keepLastSave)
+				keepLastSave = false
+			} else {
+				if firstCheck {
+					slog.Info("Neverwinter Nights 2 is not running")
+				} else {
+					slog.Info("Neverwinter Nights 2 exited")
+				}
+				sr.pauseAlarms(gamePauseReason)
+				sr.endSession()
+			}
+		}
+		firstCheck = false
+		wasRunning = running
+
+		select {
+		case <-sr.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fakeGameDetector answers each check with the next value sent on running
+type fakeGameDetector struct {
+	running chan bool
+	done    chan struct{}
+}
+
+func (d *fakeGameDetector) GameRunning() (bool, error) {
+	select {
+	case running := <-d.running:
+		return running, nil
+	case <-d.done:
+		return true, nil
+	}
+}
+
+// watchFakeGame starts watchGameProcess on sr with a fake detector and returns
+// a function that answers the next check. It returns once the answer has been
+// acted on.
+func watchFakeGame(t *testing.T, sr *SaveReminder) func(running bool) {
+	t.Helper()
+	sr.config.GameCheckInterval = "1ms"
+	sr.paused = make(map[string]time.Time)
+	sr.done = make(chan struct{})
+	detector := &fakeGameDetector{running: make(chan bool), done: sr.done}
+	finished := make(chan struct{})
+	go func() {
+		sr.watchGameProcess(detector)
+		close(finished)
+	}()
+	t.Cleanup(func() {
+		close(sr.done)
+		<-finished
+		sr.resetAlarmTimers()
+	})
+	return func(running bool) {
+		// The detector is only asked again once the last answer was dealt with
+		detector.running <- running
+		detector.running <- running
+	}
+}
+
+func TestWatchGameProcess(t *testing.T) {
+	sr := &SaveReminder{config: Config{AlarmInterval: "1h"}, lastSaveTime: time.Now().Add(-3 * time.Hour)}
+	check := watchFakeGame(t, sr)
+
+	state := func() (paused bool, lastSave, nextAlarm time.Time) {
+		sr.mu.Lock()
+		defer sr.mu.Unlock()
+		_, paused = sr.paused[gamePauseReason]
+		return paused, sr.lastSaveTime, sr.nextAlarmAt
+	}
+
+	check(false)
+	if paused, _, nextAlarm := state(); !paused || !nextAlarm.IsZero() {
+		t.Fatalf("game not running at startup: paused = %v, next alarm at %v, want paused with no alarm", paused, nextAlarm)
+	}
+
+	launched := time.Now()
+	check(true)
+	paused, lastSave, nextAlarm := state()
+	if paused {
+		t.Fatal("still paused after the game started")
+	}
+	if lastSave.Before(launched) {
+		t.Errorf("timer not restarted when the game started: last save %v, started %v", lastSave, launched)
+	}
+	if want := lastSave.Add(time.Hour); nextAlarm.Before(want.Add(-time.Second)) || nextAlarm.After(want.Add(time.Second)) {
+		t.Errorf("next alarm at %v, want alarm_interval after the start (%v)", nextAlarm, want)
+	}
+
+	check(false)
+	if paused, _, nextAlarm := state(); !paused || !nextAlarm.IsZero() {
+		t.Errorf("game exited: paused = %v, next alarm at %v, want paused with no alarm", paused, nextAlarm)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	relaunched := time.Now()
+	check(true)
+	if paused, lastSave, _ := state(); paused || lastSave.Before(relaunched) {
+		t.Errorf("game started again: paused = %v, last save %v, want the timer restarted at %v", paused, lastSave, relaunched)
+	}
+}
+
+func TestWatchGameProcessKeepsLastSaveFound(t *testing.T) {
+	// A save found at startup still counts when the game is started later
+	lastSave := time.Now().Add(-20 * time.Minute)
+	sr := &SaveReminder{config: Config{AlarmInterval: "1h"}, lastSaveTime: lastSave, lastSaveFound: true}
+	check := watchFakeGame(t, sr)
+
+	check(false)
+	time.Sleep(50 * time.Millisecond)
+	check(true)
+
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	// Only the time the game wasn't running is added
+	if sr.lastSaveTime.Before(lastSave.Add(50*time.Millisecond)) || sr.lastSaveTime.After(lastSave.Add(time.Minute)) {
+		t.Errorf("last save moved from %v to %v, want it shifted by the time the game was closed", lastSave, sr.lastSaveTime)
+	}
+}
+
+func TestScanProc(t *testing.T) {
+	type process struct{ comm, cmdline string }
+	tests := []struct {
+		name      string
+		processes []process
+		want      bool
+	}{
+		{"no game", []process{{"bash", "/bin/bash\x00"}, {"steam", "/usr/bin/steam\x00-silent\x00"}}, false},
+		{"comm", []process{{"bash", "/bin/bash\x00"}, {"nwn2main.exe", ""}}, true},
+		{"Windows path in cmdline", []process{{"nwn2main", `C:\Program Files (x86)\Atari\Neverwinter Nights 2\NWN2Main.exe` + "\x00"}}, true},
+		{"Wine loader argument", []process{{"wine64-preloade", "/usr/bin/wine64-preloader\x00nwn2main.exe\x00"}}, true},
+		{"other program under Wine", []process{{"wine64-preloade", "/usr/bin/wine64-preloader\x00notepad.exe\x00nwn2main.exe\x00"}}, false},
+		{"game name as an argument", []process{{"vim", "vim\x00nwn2main.exe\x00"}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proc := t.TempDir()
+			// Entries that aren't processes are skipped
+			os.WriteFile(filepath.Join(proc, "uptime"), []byte("1.0 1.0"), 0644)
+			os.MkdirAll(filepath.Join(proc, "sys"), 0755)
+			for i, p := range tt.processes {
+				dir := filepath.Join(proc, string(rune('1'+i)))
+				if err := os.Mkdir(dir, 0755); err != nil {
+					t.Fatal(err)
+				}
+				os.WriteFile(filepath.Join(dir, "comm"), []byte(p.comm+"\n"), 0644)
+				os.WriteFile(filepath.Join(dir, "cmdline"), []byte(p.cmdline), 0644)
+			}
+
+			d := newGameDetector(defaultGameProcessNames()).(*processDetector)
+			got, err := d.scanProc(proc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("scanProc = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,801 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// replicationQueueFile holds backups still waiting to be copied, so they
+	// are retried after a restart. It lives in the backups folder.
+	replicationQueueFile = ".replication-queue.json"
+	// replicationRetryMin and replicationRetryMax bound the delay between attempts
+	replicationRetryMin = 30 * time.Second
+	replicationRetryMax = time.Hour
+	// partialSuffix marks a backup that is still being copied to a target
+	partialSuffix = ".partial"
+	// metadataSidecarSuffix names the file next to an archive in a target that
+	// holds the backup's metadata, so a pin or note can change without the
+	// backup being packed again
+	metadataSidecarSuffix = ".info.json"
+)
+
+// replicationJob is a backup that still has to be copied to a target
+type replicationJob struct {
+	Backup      string    `json:"backup"` // Backup folder name
+	Target      string    `json:"target"` // Sink name (a target folder, or the S3 bucket)
+	Attempts    int       `json:"attempts"`
+	NextAttempt time.Time `json:"next_attempt"`
+	LastError   string    `json:"last_error,omitempty"`
+	Metadata    bool      `json:"metadata,omitempty"` // Only bring the pin and note up to date; the backup was copied already
+}
+
+// errBackupGone means a backup was deleted before it could be replicated
+var errBackupGone = errors.New("backup no longer exists")
+
+// backupSink is somewhere backups are replicated to
+type backupSink interface {
+	String() string                                // Identifies the sink in the queue and in logs
+	packsArchives() bool                           // Backups are sent as single archives, built in memory
+	upload(backupsPath, backup string) error       // Copies one backup to the sink
+	list(backupsPath string) ([]backupInfo, error) // Backups in the sink, newest first
+	download(b backupInfo, dir string) error       // Copies a backup from the sink into a new folder
+	remove(b backupInfo) error                     // Deletes a backup from the sink
+}
+
+// metadataSyncer is a sink that keeps a backup's metadata apart from the
+// backup itself, so a pin or note changed later can be brought up to date
+type metadataSyncer interface {
+	// syncMetadata updates the sink's copy of a backup's metadata and reports whether it changed
+	syncMetadata(backupsPath, backup string) (bool, error)
+}
+
+// folderSink replicates backups to a folder, e.g. a second drive or NAS mount.
+// Backups are copied as they are, or as encrypted archives if encryption is on.
+type folderSink struct {
+	path   string
+	cipher *backupCipher
+}
+
+func (f folderSink) String() string { return f.path }
+
+func (f folderSink) packsArchives() bool { return f.cipher != nil }
+
+func (f folderSink) upload(backupsPath, backup string) error {
+	if f.cipher == nil {
+		return replicateBackup(backupsPath, backup, f.path)
+	}
+	dst := filepath.Join(f.path, archiveName(backup, f.cipher))
+	if _, err := os.Stat(dst); err != nil {
+		archive, err := packBackup(filepath.Join(backupsPath, backup), f.cipher)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(f.path, 0755); err != nil {
+			return fmt.Errorf("error creating target folder: %v", err)
+		}
+		if err := writeFileAtomic(dst, archive); err != nil {
+			return err
+		}
+	}
+	_, err := f.syncMetadata(backupsPath, backup)
+	return err
+}
+
+// sidecarPath returns where an archive's metadata sidecar is kept. It is
+// encrypted like the archive, as a note may say more than the backup's name.
+func (f folderSink) sidecarPath(backup string) string {
+	return filepath.Join(f.path, sidecarName(backup, f.cipher != nil))
+}
+
+// syncMetadata brings a replica's metadata up to date with the local backup
+// and reports whether it changed anything. Backup folders carry the metadata
+// file inside; archives have it in a sidecar file, which is given the local
+// file's modification time so a later sync can tell it's current without
+// decrypting it.
+func (f folderSink) syncMetadata(backupsPath, backup string) (bool, error) {
+	src := filepath.Join(backupsPath, backup)
+	if f.cipher == nil {
+		return syncBackupMetadata(src, filepath.Join(f.path, backup))
+	}
+	local, err := os.Stat(filepath.Join(src, backupMetadataFile))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error reading backup metadata: %v", err)
+	}
+	dst := f.sidecarPath(backup)
+	// FAT drives only keep modification times to two seconds
+	if replica, err := os.Stat(dst); err == nil {
+		if diff := replica.ModTime().Sub(local.ModTime()); diff > -2*time.Second && diff < 2*time.Second {
+			return false, nil
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(src, backupMetadataFile))
+	if err != nil {
+		return false, fmt.Errorf("error reading backup metadata: %v", err)
+	}
+	encrypted, err := f.cipher.encrypt(data)
+	if err != nil {
+		return false, err
+	}
+	if err := writeFileAtomic(dst, encrypted); err != nil {
+		return false, err
+	}
+	os.Chtimes(dst, local.ModTime(), local.ModTime())
+	return true, nil
+}
+
+// readSidecar reads the metadata kept next to an archive; archives without
+// a sidecar get the zero value
+func (f folderSink) readSidecar(backup string) (backupMetadata, error) {
+	path := f.sidecarPath(backup)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return backupMetadata{}, nil
+	}
+	if err != nil {
+		return backupMetadata{}, fmt.Errorf("error reading backup metadata: %v", err)
+	}
+	return unpackSidecar(data, filepath.Base(path), f.cipher)
+}
+
+// list returns the backup folders and archives in the target. Archives get
+// their metadata from the local backup of the same name, if there still is
+// one, and otherwise from their sidecar file.
+func (f folderSink) list(backupsPath string) ([]backupInfo, error) {
+	entries, err := os.ReadDir(f.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading target folder: %v", err)
+	}
+
+	var backups []backupInfo
+	for _, entry := range entries {
+		name := entry.Name()
+		metaPath := filepath.Join(f.path, name)
+		if entry.IsDir() {
+			if strings.HasSuffix(name, partialSuffix) {
+				continue
+			}
+		} else {
+			var ok bool
+			if name, _, ok = parseArchiveName(name); !ok {
+				continue
+			}
+			metaPath = filepath.Join(backupsPath, name)
+		}
+		t, label, ok := parseBackupName(name)
+		if !ok {
+			continue
+		}
+		b := backupInfo{Name: name, Path: filepath.Join(f.path, entry.Name()), Time: t, Label: label}
+		if _, err := os.Stat(metaPath); err != nil && !entry.IsDir() {
+			b.backupMetadata, err = f.readSidecar(name)
+			if err != nil {
+				slog.Warn("Skipping backup metadata", "error", err)
+			}
+		} else if b.backupMetadata, err = readBackupMetadata(metaPath); err != nil {
+			slog.Warn("Skipping backup metadata", "error", err)
+		}
+		backups = append(backups, b)
+	}
+	sortBackups(backups)
+	return backups, nil
+}
+
+func (f folderSink) download(b backupInfo, dir string) error {
+	info, err := os.Stat(b.Path)
+	if err != nil {
+		return fmt.Errorf("error reading backup: %v", err)
+	}
+	if info.IsDir() {
+		return copyDirectory(b.Path, dir)
+	}
+	archive, err := os.ReadFile(b.Path)
+	if err != nil {
+		return fmt.Errorf("error reading backup: %v", err)
+	}
+	if err := unpackBackup(archive, filepath.Base(b.Path), f.cipher, dir); err != nil {
+		return err
+	}
+	// The archive holds the metadata as it was when the backup was made
+	if _, err := os.Stat(f.sidecarPath(b.Name)); err == nil {
+		meta, err := f.readSidecar(b.Name)
+		if err != nil {
+			return err
+		}
+		return writeBackupMetadata(dir, meta)
+	}
+	return nil
+}
+
+func (f folderSink) remove(b backupInfo) error {
+	if err := os.RemoveAll(b.Path); err != nil {
+		return fmt.Errorf("error deleting backup: %v", err)
+	}
+	if err := os.Remove(f.sidecarPath(b.Name)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error deleting backup metadata: %v", err)
+	}
+	return nil
+}
+
+// replicator copies new backups to the replication sinks in the background
+type replicator struct {
+	mu          sync.Mutex
+	backupsPath string
+	sinks       []backupSink
+	queuePath   string
+	jobs        []replicationJob
+	wake        chan struct{}
+}
+
+// resolveReplicationTargets resolves the configured targets; relative paths are
+// taken relative to the executable directory
+func resolveReplicationTargets(config Config) []string {
+	var targets []string
+	for _, target := range config.ReplicationTargets {
+		if target != "" {
+			targets = append(targets, resolveExePath(target))
+		}
+	}
+	return targets
+}
+
+// replicationSinks returns every configured replication target and the S3
+// bucket. If encryption is configured but unusable, it fails rather than let
+// backups leave the computer unencrypted.
+func replicationSinks(config Config) ([]backupSink, error) {
+	cipher, err := newBackupCipher(config.Encryption)
+	if err != nil {
+		return nil, fmt.Errorf("backup encryption: %v", err)
+	}
+	var sinks []backupSink
+	for _, target := range resolveReplicationTargets(config) {
+		sinks = append(sinks, folderSink{path: target, cipher: cipher})
+	}
+	if sink, err := newS3Sink(config, cipher); err != nil {
+		slog.Warn("S3 backups disabled", "error", err)
+	} else if sink != nil {
+		sinks = append(sinks, sink)
+	}
+	return sinks, nil
+}
+
+// takesBackup reports whether a backup is replicated to a sink. Archives are
+// built in memory, which suits a quicksave slot but not a full snapshot of the
+// game folder, so snapshots only go to targets that copy backup folders as they are.
+func takesBackup(sink backupSink, backup string) bool {
+	_, label, _ := parseBackupName(backup)
+	return label != fullSnapshotLabel || !sink.packsArchives()
+}
+
+// newReplicator creates a replicator and loads the jobs left over from the last run.
+// Jobs for sinks that are no longer configured are dropped.
+func newReplicator(backupsPath string, sinks []backupSink) *replicator {
+	r := &replicator{
+		backupsPath: backupsPath,
+		sinks:       sinks,
+		queuePath:   filepath.Join(backupsPath, replicationQueueFile),
+		wake:        make(chan struct{}, 1),
+	}
+
+	data, err := os.ReadFile(r.queuePath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			slog.Warn("Could not read replication queue", "error", err)
+		}
+		return r
+	}
+	var jobs []replicationJob
+	if err := json.Unmarshal(data, &jobs); err != nil {
+		slog.Warn("Invalid replication queue, starting with an empty one", "path", r.queuePath, "error", err)
+		return r
+	}
+	configured := make(map[string]backupSink)
+	for _, sink := range sinks {
+		configured[sink.String()] = sink
+	}
+	for _, job := range jobs {
+		if sink, ok := configured[job.Target]; ok && takesBackup(sink, job.Backup) {
+			r.jobs = append(r.jobs, job)
+		}
+	}
+	if len(r.jobs) > 0 {
+		slog.Info("Resuming backup replication", "pending", len(r.jobs))
+	}
+	return r
+}
+
+// enqueue queues a backup for every sink and wakes the worker
+func (r *replicator) enqueue(backup string) {
+	r.mu.Lock()
+	for _, sink := range r.sinks {
+		if takesBackup(sink, backup) {
+			r.jobs = append(r.jobs, replicationJob{Backup: backup, Target: sink.String(), NextAttempt: time.Now()})
+		}
+	}
+	r.saveLocked()
+	r.mu.Unlock()
+
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// enqueueMetadata queues a metadata update for a backup whose pin or note
+// changed and wakes the worker. Sinks still waiting to copy the backup, or
+// already due to update it, get the new metadata then.
+func (r *replicator) enqueueMetadata(backup string) {
+	r.mu.Lock()
+	for _, sink := range r.sinks {
+		if _, ok := sink.(metadataSyncer); !ok || !takesBackup(sink, backup) || r.pendingLocked(backup, sink.String()) {
+			continue
+		}
+		r.jobs = append(r.jobs, replicationJob{Backup: backup, Target: sink.String(), NextAttempt: time.Now(), Metadata: true})
+	}
+	r.saveLocked()
+	r.mu.Unlock()
+
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// pendingLocked reports whether a sink has a job for a backup. r.mu must be held.
+func (r *replicator) pendingLocked(backup, target string) bool {
+	for _, job := range r.jobs {
+		if job.Backup == backup && job.Target == target {
+			return true
+		}
+	}
+	return false
+}
+
+// replicateMetadata sends a backup's changed pin or note to the replication
+// targets, so pruning there keeps exactly the backups pinned here
+func (sr *SaveReminder) replicateMetadata(backup string) {
+	if sr.replicator != nil {
+		sr.replicator.enqueueMetadata(backup)
+	}
+}
+
+// queued reports whether a backup is still waiting to be copied to a sink
+func (r *replicator) queued(backup string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, job := range r.jobs {
+		if job.Backup == backup {
+			return true
+		}
+	}
+	return false
+}
+
+// run processes the queue until done is closed
+func (r *replicator) run(done <-chan struct{}) {
+	for {
+		wait := r.processDue()
+		timer := time.NewTimer(wait)
+		select {
+		case <-done:
+			timer.Stop()
+			return
+		case <-r.wake:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// processDue attempts every job that is due and returns how long to wait for the next one
+func (r *replicator) processDue() time.Duration {
+	r.mu.Lock()
+	var due []replicationJob
+	for _, job := range r.jobs {
+		if !job.NextAttempt.After(time.Now()) {
+			due = append(due, job)
+		}
+	}
+	r.mu.Unlock()
+
+	// Copy without holding the lock; new jobs can be queued meanwhile
+	for _, job := range due {
+		err := errBackupGone
+		if _, statErr := os.Stat(filepath.Join(r.backupsPath, job.Backup)); statErr == nil {
+			sink := r.sink(job.Target)
+			if syncer, ok := sink.(metadataSyncer); ok && job.Metadata {
+				_, err = syncer.syncMetadata(r.backupsPath, job.Backup)
+			} else {
+				err = sink.upload(r.backupsPath, job.Backup)
+			}
+		}
+		r.finish(job, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	wait := replicationRetryMax
+	for _, job := range r.jobs {
+		if d := time.Until(job.NextAttempt); d < wait {
+			wait = d
+		}
+	}
+	if wait < time.Second {
+		wait = time.Second
+	}
+	return wait
+}
+
+// sink returns the sink with the given name
+func (r *replicator) sink(name string) backupSink {
+	for _, sink := range r.sinks {
+		if sink.String() == name {
+			return sink
+		}
+	}
+	return folderSink{path: name}
+}
+
+// finish removes a job that succeeded, or schedules a retry with backoff
+func (r *replicator) finish(job replicationJob, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, j := range r.jobs {
+		if j.Backup != job.Backup || j.Target != job.Target || j.Metadata != job.Metadata {
+			continue
+		}
+		switch {
+		case err == nil && job.Metadata:
+			slog.Info("Backup pin and note replicated", "backup", job.Backup, "target", job.Target)
+			r.jobs = append(r.jobs[:i], r.jobs[i+1:]...)
+		case err == nil:
+			slog.Info("Backup replicated", "backup", job.Backup, "target", job.Target)
+			r.jobs = append(r.jobs[:i], r.jobs[i+1:]...)
+		case err == errBackupGone:
+			slog.Warn("Backup was deleted before it was replicated", "backup", job.Backup, "target", job.Target)
+			r.jobs = append(r.jobs[:i], r.jobs[i+1:]...)
+		default:
+			j.Attempts++
+			j.LastError = err.Error()
+			j.NextAttempt = time.Now().Add(retryDelay(j.Attempts))
+			r.jobs[i] = j
+			slog.Warn("Backup replication failed, will retry", "backup", job.Backup, "target", job.Target,
+				"attempt", j.Attempts, "retry_at", j.NextAttempt, "error", err)
+		}
+		break
+	}
+	r.saveLocked()
+}
+
+// retryDelay doubles the wait after each failed attempt, up to replicationRetryMax
+func retryDelay(attempts int) time.Duration {
+	delay := replicationRetryMin
+	for i := 1; i < attempts && delay < replicationRetryMax; i++ {
+		delay *= 2
+	}
+	if delay > replicationRetryMax {
+		delay = replicationRetryMax
+	}
+	return delay
+}
+
+// saveLocked writes the queue to disk. r.mu must be held.
+func (r *replicator) saveLocked() {
+	if len(r.jobs) == 0 {
+		if err := os.Remove(r.queuePath); err != nil && !os.IsNotExist(err) {
+			slog.Warn("Could not remove replication queue", "error", err)
+		}
+		return
+	}
+	data, err := json.MarshalIndent(r.jobs, "", "  ")
+	if err == nil {
+		err = writeFileAtomic(r.queuePath, data)
+	}
+	if err != nil {
+		slog.Warn("Could not save replication queue", "error", err)
+	}
+}
+
+// writeFileAtomic writes a file via a temporary file and a rename, so readers
+// never see a half-written file
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("error writing %s: %v", tmp, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("error replacing %s: %v", path, err)
+	}
+	return nil
+}
+
+// replicateBackup copies a backup folder to a target. The copy is made under a
+// temporary name and renamed when complete, so a target never holds a partial
+// backup under the real name. A backup that is already there is left alone
+// apart from its metadata, which may have changed (e.g. pinned).
+func replicateBackup(backupsPath, backup, target string) (err error) {
+	src := filepath.Join(backupsPath, backup)
+	dst := filepath.Join(target, backup)
+	if _, err := os.Stat(src); err != nil {
+		return errBackupGone
+	}
+	if _, err := os.Stat(dst); err == nil {
+		_, err := syncBackupMetadata(src, dst)
+		return err
+	}
+
+	if err := os.MkdirAll(target, 0755); err != nil {
+		return fmt.Errorf("error creating target folder: %v", err)
+	}
+	partial := filepath.Join(target, backup+partialSuffix)
+	os.RemoveAll(partial)
+	if err := copyDirectory(src, partial); err != nil {
+		os.RemoveAll(partial)
+		return err
+	}
+	if err := os.Rename(partial, dst); err != nil {
+		os.RemoveAll(partial)
+		return fmt.Errorf("error finishing copy: %v", err)
+	}
+	return nil
+}
+
+// syncBackupMetadata copies a backup's metadata file to a replica if it differs
+// and reports whether it did
+func syncBackupMetadata(src, dst string) (bool, error) {
+	want, err := os.ReadFile(filepath.Join(src, backupMetadataFile))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error reading backup metadata: %v", err)
+	}
+	if _, err := os.Stat(dst); err != nil {
+		// The backup itself isn't there; copying it brings the metadata along
+		return false, nil
+	}
+	have, _ := os.ReadFile(filepath.Join(dst, backupMetadataFile))
+	if bytes.Equal(want, have) {
+		return false, nil
+	}
+	if err := writeFileAtomic(filepath.Join(dst, backupMetadataFile), want); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// fetchBackup copies a backup from a sink into the backups folder and returns
+// the local copy
+func fetchBackup(sink backupSink, b backupInfo, backupsPath string) (backupInfo, error) {
+	dst := filepath.Join(backupsPath, b.Name)
+	if _, err := os.Stat(dst); err == nil {
+		return backupInfo{}, fmt.Errorf("backup %s already exists locally", b.Name)
+	}
+	partial := dst + partialSuffix
+	os.RemoveAll(partial)
+	if err := sink.download(b, partial); err != nil {
+		os.RemoveAll(partial)
+		return backupInfo{}, err
+	}
+	if err := os.Rename(partial, dst); err != nil {
+		os.RemoveAll(partial)
+		return backupInfo{}, fmt.Errorf("error finishing copy: %v", err)
+	}
+
+	local := b
+	local.Path = dst
+	local.backupMetadata, _ = readBackupMetadata(dst)
+	return local, nil
+}
+
+// runSync implements the sync command: it brings every replication target and
+// the S3 bucket up to date with the backups folder. With --prune, backups that
+// are no longer in the backups folder are also removed (pinned ones are kept).
+func runSync(config Config, args []string) int {
+	flags := flag.NewFlagSet("sync", flag.ContinueOnError)
+	prune := flags.Bool("prune", false, "remove backups from the targets that were deleted locally")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	sinks, err := replicationSinks(config)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if len(sinks) == 0 {
+		fmt.Fprintln(os.Stderr, "No replication targets (set replication_targets or s3 in config.json)")
+		return 1
+	}
+	_, savesPath := getSavesFolder()
+	backupsPath := filepath.Join(savesPath, backupFolderName)
+	backups, err := listBackups(backupsPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not list backups: %v\n", err)
+		return 1
+	}
+
+	status := 0
+	for _, sink := range sinks {
+		fmt.Printf("%s:\n", sink)
+		remote, err := sink.list(backupsPath)
+		if err != nil {
+			fmt.Printf("  Could not list backups: %v\n", err)
+			status = 1
+			continue
+		}
+		replicas := make(map[string]backupInfo)
+		for _, b := range remote {
+			replicas[b.Name] = b
+		}
+
+		copied, updated, removed, failed := 0, 0, 0, 0
+		local := make(map[string]bool)
+		for _, b := range backups {
+			local[b.Name] = true
+			if !takesBackup(sink, b.Name) {
+				continue
+			}
+			if _, ok := replicas[b.Name]; ok {
+				// The metadata may have changed since the backup was copied
+				if syncer, ok := sink.(metadataSyncer); ok {
+					changed, err := syncer.syncMetadata(backupsPath, b.Name)
+					if err != nil {
+						fmt.Printf("  %s: %v\n", b.Name, err)
+						failed++
+					} else if changed {
+						updated++
+					}
+				}
+				continue
+			}
+			if err := sink.upload(backupsPath, b.Name); err != nil {
+				fmt.Printf("  %s: %v\n", b.Name, err)
+				failed++
+				continue
+			}
+			copied++
+		}
+
+		if *prune {
+			for _, b := range remote {
+				if local[b.Name] || b.Pinned {
+					continue
+				}
+				if err := sink.remove(b); err != nil {
+					fmt.Printf("  %s: %v\n", b.Name, err)
+					failed++
+					continue
+				}
+				removed++
+			}
+		}
+
+		fmt.Printf("  %d copied, %d metadata updated, %d removed, %d failed\n", copied, updated, removed, failed)
+		if failed > 0 {
+			status = 1
+		}
+	}
+	return status
+}
+
+// runVerify implements the verify command: it reads back every backup in the
+// replication targets and the S3 bucket, decrypting it if needed, and compares
+// it with the local backup of the same name
+func runVerify(config Config, args []string) int {
+	sinks, err := replicationSinks(config)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if len(sinks) == 0 {
+		fmt.Fprintln(os.Stderr, "No replication targets (set replication_targets or s3 in config.json)")
+		return 1
+	}
+	_, savesPath := getSavesFolder()
+	backupsPath := filepath.Join(savesPath, backupFolderName)
+	backups, err := listBackups(backupsPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not list backups: %v\n", err)
+		return 1
+	}
+	tmp, err := os.MkdirTemp("", "nwn2-verify-")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not create temporary folder: %v\n", err)
+		return 1
+	}
+	defer os.RemoveAll(tmp)
+
+	status := 0
+	for i, sink := range sinks {
+		fmt.Printf("%s:\n", sink)
+		remote, err := sink.list(backupsPath)
+		if err != nil {
+			fmt.Printf("  Could not list backups: %v\n", err)
+			status = 1
+			continue
+		}
+		replicated := make(map[string]bool)
+		ok, failed := 0, 0
+		for j, b := range remote {
+			replicated[b.Name] = true
+			if err := verifyReplica(sink, b, backupsPath, filepath.Join(tmp, fmt.Sprintf("%d-%d", i, j))); err != nil {
+				fmt.Printf("  %s: %v\n", b.Name, err)
+				failed++
+				continue
+			}
+			ok++
+		}
+		missing := 0
+		for _, b := range backups {
+			if !replicated[b.Name] && takesBackup(sink, b.Name) {
+				missing++
+			}
+		}
+
+		fmt.Printf("  %d ok, %d failed, %d not replicated yet\n", ok, failed, missing)
+		if failed > 0 {
+			status = 1
+		}
+	}
+	return status
+}
+
+// verifyReplica reads a backup back from a sink and compares it with the local
+// backup of the same name. Backups that are only kept remotely can only be
+// checked for being readable.
+func verifyReplica(sink backupSink, b backupInfo, backupsPath, dir string) error {
+	defer os.RemoveAll(dir)
+	if err := sink.download(b, dir); err != nil {
+		return err
+	}
+	local := filepath.Join(backupsPath, b.Name)
+	if _, err := os.Stat(local); err != nil {
+		return nil
+	}
+
+	want, err := hashDirectory(local)
+	if err != nil {
+		return err
+	}
+	got, err := hashDirectory(dir)
+	if err != nil {
+		return err
+	}
+	// Metadata like the pin status changes after the backup is made
+	delete(want, backupMetadataFile)
+	delete(got, backupMetadataFile)
+	for name, hash := range want {
+		switch got[name] {
+		case "":
+			return fmt.Errorf("%s is missing", name)
+		case hash:
+		default:
+			return fmt.Errorf("%s differs from the local backup", name)
+		}
+	}
+	for name := range got {
+		if want[name] == "" {
+			return fmt.Errorf("%s is not in the local backup", name)
+		}
+	}
+	return nil
+}
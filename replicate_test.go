@@ -0,0 +1,55 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPinReachesReplicationTargets(t *testing.T) {
+	backupsPath := t.TempDir()
+	target := t.TempDir()
+	const name = "2024-03-01_12-00-00 - 000000 - quicksave"
+	os.Mkdir(filepath.Join(backupsPath, name), 0755)
+	os.WriteFile(filepath.Join(backupsPath, name, "save.dat"), []byte("save"), 0644)
+
+	r := newReplicator(backupsPath, []backupSink{folderSink{path: target}})
+	sr := &SaveReminder{backupsPath: backupsPath, replicator: r}
+	r.enqueue(name)
+	r.processDue()
+
+	replicaPinned := func() bool {
+		meta, err := readBackupMetadata(filepath.Join(target, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return meta.Pinned
+	}
+	for _, command := range []string{"pin latest", "unpin latest"} {
+		if reply, _ := sr.handleControlCommand(command, "test"); reply == "" || strings.HasPrefix(reply, "error") {
+			t.Fatalf("%s: %s", command, reply)
+		}
+		if !r.queued(name) {
+			t.Fatalf("%s: no metadata update queued", command)
+		}
+		r.processDue()
+		if got, want := replicaPinned(), command == "pin latest"; got != want {
+			t.Errorf("after %s the replica is pinned = %v, want %v", command, got, want)
+		}
+		if r.queued(name) {
+			t.Errorf("%s: metadata update still queued after it ran", command)
+		}
+	}
+}
+
+func TestEnqueueMetadataWhileCopyPending(t *testing.T) {
+	r := newReplicator(t.TempDir(), []backupSink{folderSink{path: t.TempDir()}})
+	r.enqueue("backup")
+	r.enqueueMetadata("backup")
+	r.enqueueMetadata("backup")
+	// The pending copy takes the new metadata along
+	if len(r.jobs) != 1 || r.jobs[0].Metadata {
+		t.Errorf("jobs = %+v, want just the copy", r.jobs)
+	}
+}
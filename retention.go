@@ -0,0 +1,73 @@
+package main
+
+import (
+	"log/slog"
+	"time"
+)
+
+// RetentionPolicy limits how many backups are kept, locally and in remote storage
+type RetentionPolicy struct {
+	KeepLast int    `json:"keep_last"` // Keep at most this many backups of each label (0 = no limit)
+	MaxAge   string `json:"max_age"`   // Delete backups older than this (e.g., "720h", empty = no limit)
+}
+
+// enabled reports whether the policy ever deletes anything
+func (p RetentionPolicy) enabled() bool {
+	return p.KeepLast > 0 || p.MaxAge != ""
+}
+
+// expired returns the backups the policy no longer keeps. backups must be
+// sorted newest first. Each label (quicksave, a character's vault backups,
+// full snapshots, ...) is counted on its own, so frequent backups of one kind
+// don't push out the others. Pinned backups are always kept and don't count
+// towards keep_last, and the newest unpinned backup of a label is never expired.
+func (p RetentionPolicy) expired(backups []backupInfo, now time.Time) []backupInfo {
+	var maxAge time.Duration
+	if p.MaxAge != "" {
+		var err error
+		maxAge, err = time.ParseDuration(p.MaxAge)
+		if err != nil || maxAge <= 0 {
+			slog.Warn("Invalid retention max_age in config, ignoring it", "max_age", p.MaxAge, "error", err)
+			maxAge = 0
+		}
+	}
+
+	var expired []backupInfo
+	kept := make(map[string]int)
+	for _, b := range backups {
+		if b.Pinned {
+			continue
+		}
+		label := baseLabel(b.Label)
+		kept[label]++
+		if kept[label] == 1 {
+			continue
+		}
+		if (p.KeepLast > 0 && kept[label] > p.KeepLast) || (maxAge > 0 && now.Sub(b.Time) > maxAge) {
+			expired = append(expired, b)
+		}
+	}
+	return expired
+}
+
+// pruneBackups deletes the local backups the retention policy no longer keeps.
+// Backups still waiting to be replicated are kept until they have been copied.
+func (sr *SaveReminder) pruneBackups() {
+	if !sr.config.Retention.enabled() {
+		return
+	}
+	backups, err := listBackups(sr.backupsPath)
+	if err != nil {
+		slog.Warn("Could not apply retention policy", "error", err)
+		return
+	}
+	for _, b := range sr.config.Retention.expired(backups, time.Now()) {
+		if sr.replicator != nil && sr.replicator.queued(b.Name) {
+			slog.Debug("Keeping expired backup until it has been replicated", "backup", b.Name)
+			continue
+		}
+		if err := sr.deleteBackup(b); err != nil {
+			slog.Warn("Could not prune backup", "backup", b.Name, "error", err)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testBackups makes backups one hour apart, newest first, from labels in
+// that order. A label ending in "*" is pinned.
+func testBackups(now time.Time, labels ...string) []backupInfo {
+	var backups []backupInfo
+	for i, label := range labels {
+		b := backupInfo{Time: now.Add(-time.Duration(i) * time.Hour), Label: label}
+		if label[len(label)-1] == '*' {
+			b.Label, b.Pinned = label[:len(label)-1], true
+		}
+		b.Name = b.Time.Format(backupTimestampFormat) + " - " + b.Label
+		backups = append(backups, b)
+	}
+	return backups
+}
+
+func TestRetentionExpired(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.Local)
+	const (
+		qs    = "000000 - quicksave"
+		vault = "vault - Hero"
+		snap  = "full snapshot"
+	)
+	tests := []struct {
+		name    string
+		policy  RetentionPolicy
+		labels  []string
+		expired []int // Indexes into labels
+	}{
+		{"disabled", RetentionPolicy{}, []string{qs, qs, qs}, nil},
+		{"keep last", RetentionPolicy{KeepLast: 2}, []string{qs, qs, qs, qs}, []int{2, 3}},
+		{"pinned don't count", RetentionPolicy{KeepLast: 1}, []string{qs + "*", qs, qs + "*", qs}, []int{3}},
+		{"labels counted separately", RetentionPolicy{KeepLast: 1},
+			[]string{vault, vault, vault, qs, snap, qs}, []int{1, 2, 5}},
+		{"vault backups don't push out saves", RetentionPolicy{KeepLast: 2},
+			[]string{vault, vault, vault, vault, qs, snap}, []int{2, 3}},
+		{"same-second vault backups count with their character", RetentionPolicy{KeepLast: 1},
+			[]string{vault + " (2)", vault, "vault - Hero (Ranger)"}, []int{1}},
+		{"max age", RetentionPolicy{MaxAge: "90m"}, []string{qs, qs, qs, qs}, []int{2, 3}},
+		{"newest of a label survives max age", RetentionPolicy{MaxAge: "30m"},
+			[]string{qs, qs, snap}, []int{1}},
+		{"invalid max age is ignored", RetentionPolicy{MaxAge: "soon"}, []string{qs, qs}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backups := testBackups(now, tt.labels...)
+			var want []string
+			for _, i := range tt.expired {
+				want = append(want, backups[i].Name)
+			}
+			var got []string
+			for _, b := range tt.policy.expired(backups, now) {
+				got = append(got, b.Name)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("expired = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestReplicatorQueued(t *testing.T) {
+	r := &replicator{jobs: []replicationJob{{Backup: "a", Target: "t"}}}
+	if !r.queued("a") {
+		t.Error("queued backup not reported")
+	}
+	if r.queued("b") {
+		t.Error("backup that isn't queued reported as queued")
+	}
+}
+
+func TestPruneBackupsKeepsQueuedBackups(t *testing.T) {
+	dir := t.TempDir()
+	backups := testBackups(time.Now(), "000000 - quicksave", "000000 - quicksave", "000000 - quicksave")
+	for _, b := range backups {
+		if err := os.Mkdir(filepath.Join(dir, b.Name), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sr := &SaveReminder{
+		backupsPath: dir,
+		config:      Config{Retention: RetentionPolicy{KeepLast: 1}},
+		replicator:  &replicator{jobs: []replicationJob{{Backup: backups[1].Name, Target: "nas"}}},
+	}
+	sr.pruneBackups()
+
+	for i, b := range backups {
+		_, err := os.Stat(filepath.Join(dir, b.Name))
+		if exists, want := err == nil, i < 2; exists != want {
+			t.Errorf("%s exists = %v, want %v", b.Name, exists, want)
+		}
+	}
+}
+
+func TestFullSnapshotPrunesOldSnapshots(t *testing.T) {
+	userFolder := t.TempDir()
+	savesPath := filepath.Join(userFolder, "saves", "multiplayer")
+	backupsPath := filepath.Join(savesPath, backupFolderName)
+	vault := filepath.Join(userFolder, "localvault")
+	for _, dir := range []string{backupsPath, vault} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	os.WriteFile(filepath.Join(vault, "hero.bic"), []byte("bic"), 0644)
+	old := testBackups(time.Now().Add(-time.Hour), fullSnapshotLabel, fullSnapshotLabel, "000000 - quicksave")
+	for _, b := range old {
+		if err := os.Mkdir(filepath.Join(backupsPath, b.Name), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sr := &SaveReminder{
+		savesPath:   savesPath,
+		backupsPath: backupsPath,
+		config: Config{
+			FullSnapshot: FullSnapshotConfig{Paths: []string{"localvault"}},
+			Retention:    RetentionPolicy{KeepLast: 1},
+		},
+	}
+	name, err := sr.takeFullSnapshot(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backups, err := listBackups(backupsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, b := range backups {
+		got = append(got, b.Name)
+	}
+	if want := []string{name, old[2].Name}; !reflect.DeepEqual(got, want) {
+		t.Errorf("backups after the snapshot = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,625 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3Config describes an S3-compatible bucket that backups are uploaded to
+type S3Config struct {
+	Endpoint  string `json:"endpoint"`   // e.g. "https://s3.eu-central-1.amazonaws.com" or "http://nas:9000" (empty = disabled)
+	Region    string `json:"region"`     // Signing region (default: "us-east-1")
+	Bucket    string `json:"bucket"`     // Bucket name
+	Prefix    string `json:"prefix"`     // Key prefix, e.g. "nwn2/" (empty = bucket root)
+	AccessKey string `json:"access_key"` // Access key (empty = AWS_ACCESS_KEY_ID)
+	SecretKey string `json:"secret_key"` // Secret key (empty = AWS_SECRET_ACCESS_KEY)
+}
+
+// errS3NotFound means the requested object isn't in the bucket
+var errS3NotFound = errors.New("no such object")
+
+// s3Client talks to an S3-compatible service using path-style URLs and
+// Signature Version 4, which AWS, MinIO, Backblaze B2 and others all accept
+type s3Client struct {
+	endpoint  *url.URL
+	region    string
+	bucket    string
+	accessKey string
+	secretKey string
+	http      *http.Client
+}
+
+// s3Object is an entry in a bucket listing
+type s3Object struct {
+	Key          string    `xml:"Key"`
+	Size         int64     `xml:"Size"`
+	LastModified time.Time `xml:"LastModified"`
+}
+
+// newS3Client creates a client from the config, or returns nil if S3 isn't configured
+func newS3Client(config S3Config) (*s3Client, error) {
+	if config.Endpoint == "" {
+		return nil, nil
+	}
+	endpoint, err := url.Parse(config.Endpoint)
+	if err != nil || endpoint.Host == "" || (endpoint.Scheme != "http" && endpoint.Scheme != "https") {
+		return nil, fmt.Errorf("invalid s3 endpoint %q", config.Endpoint)
+	}
+	if config.Bucket == "" {
+		return nil, fmt.Errorf("s3 bucket is not set")
+	}
+	c := &s3Client{
+		endpoint:  endpoint,
+		region:    config.Region,
+		bucket:    config.Bucket,
+		accessKey: config.AccessKey,
+		secretKey: config.SecretKey,
+		http:      &http.Client{Timeout: 5 * time.Minute},
+	}
+	if c.region == "" {
+		c.region = "us-east-1"
+	}
+	if c.accessKey == "" {
+		c.accessKey = os.Getenv("AWS_ACCESS_KEY_ID")
+	}
+	if c.secretKey == "" {
+		c.secretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+	}
+	if c.accessKey == "" || c.secretKey == "" {
+		return nil, fmt.Errorf("s3 credentials are not set")
+	}
+	return c, nil
+}
+
+// do sends a signed request for an object (or the bucket, if key is empty)
+// and returns the response if the status is 2xx
+func (c *s3Client) do(method, key string, query url.Values, header http.Header, body []byte) (*http.Response, error) {
+	u := *c.endpoint
+	u.Path = "/" + c.bucket + "/" + key
+	u.RawPath = "/" + s3Escape(c.bucket, false) + "/" + s3Escape(key, false)
+	u.RawQuery = s3CanonicalQuery(query)
+
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	c.sign(req, body, time.Now())
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		var s3Err struct {
+			Code    string `xml:"Code"`
+			Message string `xml:"Message"`
+		}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		xml.Unmarshal(data, &s3Err)
+		// HEAD responses have no body to tell a missing key from a missing bucket
+		if resp.StatusCode == http.StatusNotFound && key != "" && (s3Err.Code == "NoSuchKey" || s3Err.Code == "") {
+			return nil, fmt.Errorf("s3 %s %s: %w", method, u.Path, errS3NotFound)
+		}
+		if s3Err.Code != "" {
+			return nil, fmt.Errorf("s3 %s %s: %s: %s", method, u.Path, s3Err.Code, s3Err.Message)
+		}
+		return nil, fmt.Errorf("s3 %s %s: %s", method, u.Path, resp.Status)
+	}
+	return resp, nil
+}
+
+// sign adds a Signature Version 4 Authorization header to a request. Every
+// header already on the request is signed along with the host.
+func (c *s3Client) sign(req *http.Request, body []byte, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		// Values are trimmed and runs of spaces inside them collapsed to one
+		canonical := make([]string, len(values))
+		for i, value := range values {
+			canonical[i] = strings.Join(strings.Fields(value), " ")
+		}
+		headers[strings.ToLower(name)] = strings.Join(canonical, ",")
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + c.region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+c.secretKey), date)
+	key = hmacSHA256(key, c.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.accessKey, scope, signedHeaders, signature))
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3Escape percent-encodes everything except unreserved characters, as
+// Signature Version 4 requires. Slashes are kept in paths.
+func s3Escape(s string, escapeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || (c == '/' && !escapeSlash) {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// s3CanonicalQuery encodes a query string sorted by key, as Signature Version 4 requires
+func s3CanonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, key := range keys {
+		values := append([]string(nil), query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			parts = append(parts, s3Escape(key, true)+"="+s3Escape(value, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// putObject uploads an object with user metadata (x-amz-meta-*)
+func (c *s3Client) putObject(key, contentType string, data []byte, meta map[string]string) error {
+	header := http.Header{}
+	header.Set("Content-Type", contentType)
+	for name, value := range meta {
+		if value != "" {
+			header.Set("X-Amz-Meta-"+name, escapeMetadata(value))
+		}
+	}
+	resp, err := c.do(http.MethodPut, key, nil, header, data)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// escapeMetadata percent-encodes what can't go in an HTTP header. Metadata
+// must be ASCII, and character names often aren't.
+func escapeMetadata(value string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if c < 0x20 || c >= 0x7f || c == '%' {
+			fmt.Fprintf(&b, "%%%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// getObject downloads an object
+func (c *s3Client) getObject(key string) ([]byte, error) {
+	resp, err := c.do(http.MethodGet, key, nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+// headObject returns an object's user metadata
+func (c *s3Client) headObject(key string) (map[string]string, error) {
+	resp, err := c.do(http.MethodHead, key, nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	meta := make(map[string]string)
+	for name, values := range resp.Header {
+		name = strings.ToLower(name)
+		if !strings.HasPrefix(name, "x-amz-meta-") || len(values) == 0 {
+			continue
+		}
+		value, err := url.PathUnescape(values[0])
+		if err != nil {
+			value = values[0]
+		}
+		meta[strings.TrimPrefix(name, "x-amz-meta-")] = value
+	}
+	return meta, nil
+}
+
+// deleteObject deletes an object
+func (c *s3Client) deleteObject(key string) error {
+	resp, err := c.do(http.MethodDelete, key, nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// listObjects returns every object whose key starts with prefix
+func (c *s3Client) listObjects(prefix string) ([]s3Object, error) {
+	var objects []s3Object
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		resp, err := c.do(http.MethodGet, "", query, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		var result struct {
+			Contents              []s3Object `xml:"Contents"`
+			IsTruncated           bool       `xml:"IsTruncated"`
+			NextContinuationToken string     `xml:"NextContinuationToken"`
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error reading bucket listing: %v", err)
+		}
+		objects = append(objects, result.Contents...)
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return objects, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// s3Sink uploads each backup as a zip archive (encrypted if encryption is on)
+// and applies the retention policy to the bucket
+type s3Sink struct {
+	client    *s3Client
+	prefix    string
+	cipher    *backupCipher
+	retention RetentionPolicy
+}
+
+// newS3Sink creates the S3 sink from the config, or returns nil if S3 isn't configured
+func newS3Sink(config Config, cipher *backupCipher) (*s3Sink, error) {
+	client, err := newS3Client(config.S3)
+	if err != nil || client == nil {
+		return nil, err
+	}
+	prefix := strings.TrimPrefix(config.S3.Prefix, "/")
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return &s3Sink{client: client, prefix: prefix, cipher: cipher, retention: config.Retention}, nil
+}
+
+func (s *s3Sink) packsArchives() bool { return true }
+
+func (s *s3Sink) String() string {
+	return "s3://" + s.client.bucket + "/" + s.prefix
+}
+
+// upload archives a backup, uploads it with its timestamp, character and
+// module as metadata, and then prunes the bucket. A pin or note goes into a
+// sidecar object next to the archive. Object metadata is never encrypted, so
+// with encryption on the character and module are left out.
+func (s *s3Sink) upload(backupsPath, backup string) error {
+	src := filepath.Join(backupsPath, backup)
+	archive, err := packBackup(src, s.cipher)
+	if err != nil {
+		return err
+	}
+	local, err := readBackupMetadata(src)
+	if err != nil {
+		return err
+	}
+	meta := make(map[string]string)
+	if s.cipher == nil {
+		info := readSaveInfo(src)
+		meta["character"] = info.Character
+		meta["module"] = info.Module
+	}
+	if t, _, ok := parseBackupName(backup); ok {
+		meta["timestamp"] = t.Format(time.RFC3339)
+	}
+	if err := s.client.putObject(s.prefix+archiveName(backup, s.cipher), "application/zip", archive, meta); err != nil {
+		return err
+	}
+	// Most new backups have neither; sync uploads pins and notes added later
+	if local != (backupMetadata{}) {
+		if err := s.putSidecar(backup, local); err != nil {
+			return err
+		}
+	}
+	// The upload itself worked; if pruning fails it's tried again after the next one
+	s.prune(backupsPath)
+	return nil
+}
+
+func (s *s3Sink) list(backupsPath string) ([]backupInfo, error) {
+	backups, _, err := s.listArchives(backupsPath)
+	return backups, err
+}
+
+// listArchives returns the backups in the bucket, newest first, and the size
+// of each archive. Metadata like the pin status is taken from the local backup
+// of the same name, if there still is one, and otherwise from the sidecar
+// object, so pinned backups stay pinned after the local folder is lost.
+func (s *s3Sink) listArchives(backupsPath string) ([]backupInfo, map[string]int64, error) {
+	objects, err := s.client.listObjects(s.prefix)
+	if err != nil {
+		return nil, nil, err
+	}
+	keys := make(map[string]bool)
+	for _, object := range objects {
+		keys[object.Key] = true
+	}
+	var backups []backupInfo
+	sizes := make(map[string]int64)
+	for _, object := range objects {
+		name := strings.TrimPrefix(object.Key, s.prefix)
+		if strings.Contains(name, "/") {
+			continue
+		}
+		name, encrypted, ok := parseArchiveName(name)
+		if !ok {
+			continue
+		}
+		t, label, _ := parseBackupName(name)
+		b := backupInfo{Name: name, Path: object.Key, Time: t, Label: label}
+		local := filepath.Join(backupsPath, name)
+		if _, err := os.Stat(local); err != nil && keys[s.prefix+sidecarName(name, encrypted)] {
+			b.backupMetadata, err = s.readSidecar(name, encrypted)
+			if err != nil {
+				slog.Warn("Skipping backup metadata", "error", err)
+			}
+		} else if b.backupMetadata, err = readBackupMetadata(local); err != nil {
+			slog.Warn("Skipping backup metadata", "error", err)
+		}
+		backups = append(backups, b)
+		sizes[name] = object.Size
+	}
+	sortBackups(backups)
+	return backups, sizes, nil
+}
+
+func (s *s3Sink) download(b backupInfo, dir string) error {
+	archive, err := s.client.getObject(b.Path)
+	if err != nil {
+		return err
+	}
+	if err := unpackBackup(archive, path.Base(b.Path), s.cipher, dir); err != nil {
+		return err
+	}
+	// The archive holds the metadata as it was when the backup was made
+	_, encrypted, _ := parseArchiveName(path.Base(b.Path))
+	meta, err := s.readSidecar(b.Name, encrypted)
+	if errors.Is(err, errS3NotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return writeBackupMetadata(dir, meta)
+}
+
+func (s *s3Sink) remove(b backupInfo) error {
+	if err := s.client.deleteObject(b.Path); err != nil {
+		return err
+	}
+	_, encrypted, _ := parseArchiveName(path.Base(b.Path))
+	if err := s.client.deleteObject(s.prefix + sidecarName(b.Name, encrypted)); err != nil && !errors.Is(err, errS3NotFound) {
+		return err
+	}
+	return nil
+}
+
+// readSidecar reads the metadata object kept next to a backup's archive
+func (s *s3Sink) readSidecar(backup string, encrypted bool) (backupMetadata, error) {
+	name := sidecarName(backup, encrypted)
+	data, err := s.client.getObject(s.prefix + name)
+	if err != nil {
+		return backupMetadata{}, err
+	}
+	return unpackSidecar(data, name, s.cipher)
+}
+
+// putSidecar uploads a backup's metadata next to its archive
+func (s *s3Sink) putSidecar(backup string, meta backupMetadata) error {
+	data, err := packSidecar(meta, s.cipher)
+	if err != nil {
+		return err
+	}
+	contentType := "application/json"
+	if s.cipher != nil {
+		contentType = "application/octet-stream"
+	}
+	return s.client.putObject(s.prefix+sidecarName(backup, s.cipher != nil), contentType, data, nil)
+}
+
+// syncMetadata brings a backup's sidecar object up to date with the local
+// backup and reports whether it changed anything. Backups without a pin or
+// note don't need a sidecar.
+func (s *s3Sink) syncMetadata(backupsPath, backup string) (bool, error) {
+	local, err := readBackupMetadata(filepath.Join(backupsPath, backup))
+	if err != nil {
+		return false, err
+	}
+	remote, err := s.readSidecar(backup, s.cipher != nil)
+	if err != nil && !errors.Is(err, errS3NotFound) {
+		return false, err
+	}
+	if local == remote {
+		return false, nil
+	}
+	if local == (backupMetadata{}) {
+		err = s.client.deleteObject(s.prefix + sidecarName(backup, s.cipher != nil))
+	} else {
+		err = s.putSidecar(backup, local)
+	}
+	return err == nil, err
+}
+
+// prune deletes the archives the retention policy no longer keeps
+func (s *s3Sink) prune(backupsPath string) {
+	if !s.retention.enabled() {
+		return
+	}
+	backups, err := s.list(backupsPath)
+	if err != nil {
+		slog.Warn("Could not apply retention policy to S3", "error", err)
+		return
+	}
+	for _, b := range s.retention.expired(backups, time.Now()) {
+		if err := s.remove(b); err != nil {
+			slog.Warn("Could not prune S3 backup", "backup", b.Name, "error", err)
+			return
+		}
+		slog.Info("Deleted S3 backup", "backup", b.Name)
+	}
+}
+
+// runS3 implements the s3 command: listing the backups in the bucket and
+// downloading one back into the backups folder
+func runS3(config Config, args []string) int {
+	cipher, err := newBackupCipher(config.Encryption)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Backup encryption: %v\n", err)
+		return 1
+	}
+	sink, err := newS3Sink(config, cipher)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "S3 is not usable: %v\n", err)
+		return 1
+	}
+	if sink == nil {
+		fmt.Fprintln(os.Stderr, "S3 is not configured (set s3.endpoint and s3.bucket in config.json)")
+		return 1
+	}
+	_, savesPath := getSavesFolder()
+	backupsPath := filepath.Join(savesPath, backupFolderName)
+
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+	switch args[0] {
+	case "list", "ls":
+		return s3List(sink, backupsPath)
+	case "restore", "get":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "Usage: nwn2-save-reminder s3 restore <backup name|latest>")
+			return 2
+		}
+		return s3Restore(sink, backupsPath, strings.Join(args[1:], " "))
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown s3 command %q (use list or restore)\n", args[0])
+		return 2
+	}
+}
+
+// s3List prints the backups in the bucket with their metadata
+func s3List(sink *s3Sink, backupsPath string) int {
+	backups, sizes, err := sink.listArchives(backupsPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not list bucket: %v\n", err)
+		return 1
+	}
+	fmt.Printf("%d backups in %s\n\n", len(backups), sink)
+	for _, b := range backups {
+		line := fmt.Sprintf("%s  %-40s %10s", b.Time.Format("2006-01-02 15:04:05"), b.Label, formatBytes(sizes[b.Name]))
+		if meta, err := sink.client.headObject(b.Path); err == nil {
+			if meta["character"] != "" {
+				line += "  " + meta["character"]
+			}
+			if meta["module"] != "" {
+				line += " (" + meta["module"] + ")"
+			}
+		}
+		if b.Pinned {
+			line += "  [pinned]"
+		}
+		fmt.Println(line)
+	}
+	return 0
+}
+
+// s3Restore downloads a backup from the bucket into the backups folder, from
+// where it can be restored like any other backup
+func s3Restore(sink *s3Sink, backupsPath, name string) int {
+	backups, err := sink.list(backupsPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not list bucket: %v\n", err)
+		return 1
+	}
+	b, ok := findBackup(backups, name)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Backup %s is not in the bucket\n", name)
+		return 1
+	}
+
+	local, err := fetchBackup(sink, b, backupsPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not download backup: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Downloaded %s to %s\n", b.Name, local.Path)
+	fmt.Println("Restore it into the quicksave slot with the restore command, the terminal interface or the tray menu.")
+	return 0
+}
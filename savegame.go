@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// GFF field types that hold text
+const (
+	gffCExoString    = 10
+	gffResRef        = 11
+	gffCExoLocString = 12
+)
+
+// saveInfo is what a save folder tells about itself
+type saveInfo struct {
+	Character string // Player character name
+	Module    string // Module the save was made in
+}
+
+// readSaveInfo reads the character and module name from a save folder.
+// Details that can't be found are left empty.
+func readSaveInfo(folder string) saveInfo {
+	var info saveInfo
+	entries, err := os.ReadDir(folder)
+	if err != nil {
+		return info
+	}
+
+	// NWN2 isn't consistent about the case of file names
+	files := make(map[string]string)
+	var bics []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		name := strings.ToLower(entry.Name())
+		files[name] = filepath.Join(folder, entry.Name())
+		if strings.HasSuffix(name, ".bic") {
+			bics = append(bics, files[name])
+		}
+	}
+
+	if path, ok := files["currentmodule.txt"]; ok {
+		if data, err := os.ReadFile(path); err == nil {
+			info.Module = strings.TrimSpace(decodeGFFText(data))
+		}
+	}
+
+	// The player list names the characters in a multiplayer save;
+	// single-player saves carry the character file instead
+	candidates := bics
+	if path, ok := files["playerlist.ifo"]; ok {
+		candidates = append([]string{path}, bics...)
+	}
+	for _, path := range candidates {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		if name, err := gffCharacterName(data); err == nil && name != "" {
+			info.Character = name
+			break
+		}
+	}
+	return info
+}
+
+// gffCharacterName returns the first "FirstName LastName" in a GFF file
+// (a .bic character file or a playerlist.ifo)
+func gffCharacterName(data []byte) (string, error) {
+	fields, err := readGFFStrings(data, "FirstName", "LastName")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(fields["FirstName"] + " " + fields["LastName"]), nil
+}
+
+// readGFFStrings returns the first value of each of the given text fields in a
+// GFF V3.2 file, wherever they are in the struct tree. Localized strings give
+// their first substring.
+func readGFFStrings(data []byte, labels ...string) (map[string]string, error) {
+	if len(data) < 56 || !bytes.Equal(data[4:8], []byte("V3.2")) {
+		return nil, fmt.Errorf("not a GFF V3.2 file")
+	}
+	// Offsets are added up in 64 bits, so values from a damaged file can't wrap around
+	span := func(off, n uint64) ([]byte, bool) {
+		if off > uint64(len(data)) || n > uint64(len(data))-off {
+			return nil, false
+		}
+		return data[off : off+n], true
+	}
+	u32 := func(off uint64) (uint32, bool) {
+		b, ok := span(off, 4)
+		if !ok {
+			return 0, false
+		}
+		return binary.LittleEndian.Uint32(b), true
+	}
+	// The header starts with the struct table's offset and count, then the field table's
+	fieldOffset, _ := u32(16)
+	fieldCount, _ := u32(20)
+	labelOffset, _ := u32(24)
+	labelCount, _ := u32(28)
+	fieldDataOffset, _ := u32(32)
+
+	wanted := make(map[string]bool)
+	for _, label := range labels {
+		wanted[label] = true
+	}
+
+	values := make(map[string]string)
+	for i := uint32(0); i < fieldCount && len(values) < len(wanted); i++ {
+		entry := uint64(fieldOffset) + uint64(i)*12
+		fieldType, ok1 := u32(entry)
+		labelIndex, ok2 := u32(entry + 4)
+		dataOffset, ok3 := u32(entry + 8)
+		if !ok1 || !ok2 || !ok3 {
+			return nil, fmt.Errorf("truncated GFF field table")
+		}
+		if labelIndex >= labelCount {
+			continue
+		}
+		rawLabel, ok := span(uint64(labelOffset)+uint64(labelIndex)*16, 16)
+		if !ok {
+			continue
+		}
+		label := string(bytes.TrimRight(rawLabel, "\x00"))
+		if !wanted[label] {
+			continue
+		}
+		if _, seen := values[label]; seen {
+			continue
+		}
+
+		off := uint64(fieldDataOffset) + uint64(dataOffset)
+		var text []byte
+		switch fieldType {
+		case gffCExoString:
+			size, ok := u32(off)
+			if !ok {
+				continue
+			}
+			if text, ok = span(off+4, uint64(size)); !ok {
+				continue
+			}
+		case gffResRef:
+			size, ok := span(off, 1)
+			if !ok {
+				continue
+			}
+			if text, ok = span(off+1, uint64(size[0])); !ok {
+				continue
+			}
+		case gffCExoLocString:
+			// Total size, string ref, substring count, then (id, length, text) per substring
+			count, ok := u32(off + 8)
+			if !ok {
+				continue
+			}
+			if count == 0 {
+				values[label] = ""
+				continue
+			}
+			size, ok := u32(off + 16)
+			if !ok {
+				continue
+			}
+			if text, ok = span(off+20, uint64(size)); !ok {
+				continue
+			}
+		default:
+			continue
+		}
+		values[label] = decodeGFFText(text)
+	}
+	return values, nil
+}
+
+// decodeGFFText converts the game's Windows-1252 text to UTF-8
+func decodeGFFText(text []byte) string {
+	text = bytes.TrimRight(text, "\x00")
+	decoded, err := charmap.Windows1252.NewDecoder().Bytes(text)
+	if err != nil {
+		return string(text)
+	}
+	return string(decoded)
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// gffField is a text field for buildGFF
+type gffField struct {
+	label     string
+	fieldType uint32
+	value     string
+}
+
+// buildGFF builds a GFF V3.2 file with a single struct holding the given fields
+func buildGFF(fileType string, fields ...gffField) []byte {
+	const headerSize = 56
+	structOffset := uint32(headerSize)
+	fieldOffset := structOffset + 12
+	labelOffset := fieldOffset + uint32(len(fields))*12
+	fieldDataOffset := labelOffset + uint32(len(fields))*16
+
+	var fieldData []byte
+	u32 := func(b []byte, v uint32) []byte { return binary.LittleEndian.AppendUint32(b, v) }
+	var fieldTable, labels []byte
+	for i, f := range fields {
+		fieldTable = u32(fieldTable, f.fieldType)
+		fieldTable = u32(fieldTable, uint32(i))
+		fieldTable = u32(fieldTable, uint32(len(fieldData)))
+		label := make([]byte, 16)
+		copy(label, f.label)
+		labels = append(labels, label...)
+
+		switch f.fieldType {
+		case gffCExoString:
+			fieldData = u32(fieldData, uint32(len(f.value)))
+			fieldData = append(fieldData, f.value...)
+		case gffResRef:
+			fieldData = append(fieldData, byte(len(f.value)))
+			fieldData = append(fieldData, f.value...)
+		case gffCExoLocString:
+			fieldData = u32(fieldData, uint32(12+8+len(f.value)))
+			fieldData = u32(fieldData, 0xFFFFFFFF) // No string ref
+			fieldData = u32(fieldData, 1)          // One substring
+			fieldData = u32(fieldData, 0)          // English
+			fieldData = u32(fieldData, uint32(len(f.value)))
+			fieldData = append(fieldData, f.value...)
+		}
+	}
+
+	data := []byte(fileType + "V3.2")
+	for _, v := range []uint32{
+		structOffset, 1,
+		fieldOffset, uint32(len(fields)),
+		labelOffset, uint32(len(fields)),
+		fieldDataOffset, uint32(len(fieldData)),
+		fieldDataOffset + uint32(len(fieldData)), 0,
+		fieldDataOffset + uint32(len(fieldData)), 0,
+	} {
+		data = u32(data, v)
+	}
+	data = u32(data, 0xFFFFFFFF) // Top-level struct type
+	data = u32(data, 0)
+	data = u32(data, uint32(len(fields)))
+	data = append(data, fieldTable...)
+	data = append(data, labels...)
+	return append(data, fieldData...)
+}
+
+// patchGFF returns a copy of data with a little-endian uint32 written at off
+func patchGFF(data []byte, off int, v uint32) []byte {
+	patched := append([]byte(nil), data...)
+	binary.LittleEndian.PutUint32(patched[off:], v)
+	return patched
+}
+
+// gffFieldData returns where a field's data starts in a file made by buildGFF
+func gffFieldData(data []byte, field int) int {
+	u32 := func(off int) int { return int(binary.LittleEndian.Uint32(data[off:])) }
+	return u32(32) + u32(u32(16)+field*12+8)
+}
+
+func TestReadGFFStrings(t *testing.T) {
+	character := buildGFF("BIC ",
+		gffField{"Race", gffResRef, "human"},
+		gffField{"FirstName", gffCExoLocString, "Aribeth"},
+		gffField{"LastName", gffCExoLocString, "de Tylmarande"},
+		gffField{"Deity", gffCExoString, "Tyr"},
+	)
+	// The field table starts after the 56-byte header and the 12-byte struct
+	const fieldTable = 56 + 12
+	// Offsets into a localized string's data: substring count and first substring's length
+	const locCount, locLength = 8, 16
+
+	tests := []struct {
+		name    string
+		data    []byte
+		labels  []string
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name:   "localized strings",
+			data:   character,
+			labels: []string{"FirstName", "LastName"},
+			want:   map[string]string{"FirstName": "Aribeth", "LastName": "de Tylmarande"},
+		},
+		{
+			name:   "string and resref",
+			data:   character,
+			labels: []string{"Deity", "Race"},
+			want:   map[string]string{"Deity": "Tyr", "Race": "human"},
+		},
+		{
+			name:   "missing label",
+			data:   character,
+			labels: []string{"FirstName", "Subrace"},
+			want:   map[string]string{"FirstName": "Aribeth"},
+		},
+		{
+			name:   "Windows-1252 text",
+			data:   buildGFF("BIC ", gffField{"FirstName", gffCExoLocString, "Ren\xe9e"}),
+			labels: []string{"FirstName"},
+			want:   map[string]string{"FirstName": "Renée"},
+		},
+		{
+			// 0x80-0x9F are where Windows-1252 differs from Latin-1
+			name:   "Windows-1252 letters outside Latin-1",
+			data:   buildGFF("BIC ", gffField{"LastName", gffCExoLocString, "Bo\x9aek \x8crin"}),
+			labels: []string{"LastName"},
+			want:   map[string]string{"LastName": "Bošek Œrin"},
+		},
+		{
+			name:   "localized string without substrings",
+			data:   patchGFF(character, gffFieldData(character, 1)+locCount, 0),
+			labels: []string{"FirstName"},
+			want:   map[string]string{"FirstName": ""},
+		},
+		{
+			name:    "too short",
+			data:    character[:40],
+			labels:  []string{"FirstName"},
+			wantErr: true,
+		},
+		{
+			name:    "not a GFF file",
+			data:    append([]byte("RIFF\x00\x00\x00\x00"), make([]byte, 60)...),
+			labels:  []string{"FirstName"},
+			wantErr: true,
+		},
+		{
+			name:    "other GFF version",
+			data:    append([]byte("BIC V4.0"), character[8:]...),
+			labels:  []string{"FirstName"},
+			wantErr: true,
+		},
+		{
+			name:    "field count past the end",
+			data:    patchGFF(character, 20, 1000),
+			labels:  []string{"Subrace"},
+			wantErr: true,
+		},
+		{
+			name:    "field table offset past the end",
+			data:    patchGFF(character, 16, 0xFFFFFFF0),
+			labels:  []string{"FirstName"},
+			wantErr: true,
+		},
+		{
+			name:   "label index out of range",
+			data:   patchGFF(character, fieldTable+1*12+4, 99),
+			labels: []string{"FirstName", "LastName"},
+			want:   map[string]string{"LastName": "de Tylmarande"},
+		},
+		{
+			name:   "label table past the end",
+			data:   patchGFF(character, 24, 0xFFFFFF00),
+			labels: []string{"FirstName"},
+			want:   map[string]string{},
+		},
+		{
+			name:   "data offset past the end",
+			data:   patchGFF(character, fieldTable+1*12+8, 0x7FFFFFFF),
+			labels: []string{"FirstName", "LastName"},
+			want:   map[string]string{"LastName": "de Tylmarande"},
+		},
+		{
+			name:   "string length past the end",
+			data:   patchGFF(character, gffFieldData(character, 3), 0xFFFFFFF0),
+			labels: []string{"Deity"},
+			want:   map[string]string{},
+		},
+		{
+			name:   "data offset wrapping around",
+			data:   patchGFF(character, fieldTable+3*12+8, 0xFFFFFFFF),
+			labels: []string{"Deity"},
+			want:   map[string]string{},
+		},
+		{
+			name:   "localized string length past the end",
+			data:   patchGFF(character, gffFieldData(character, 1)+locLength, 0xFFFFFFF0),
+			labels: []string{"FirstName"},
+			want:   map[string]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readGFFStrings(tt.data, tt.labels...)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadGFFStringsTruncated(t *testing.T) {
+	data := buildGFF("BIC ",
+		gffField{"FirstName", gffCExoLocString, "Aribeth"},
+		gffField{"LastName", gffCExoString, "de Tylmarande"},
+		gffField{"Portrait", gffResRef, "po_aribeth"},
+	)
+	// Every cut must give an error or fewer fields, never a panic
+	for n := 0; n < len(data); n++ {
+		readGFFStrings(data[:n], "FirstName", "LastName", "Portrait")
+	}
+}
+
+func FuzzReadGFFStrings(f *testing.F) {
+	f.Add(buildGFF("BIC ", gffField{"FirstName", gffCExoLocString, "Aribeth"}))
+	f.Add(buildGFF("IFO ", gffField{"LastName", gffCExoString, "Tylmarande"}, gffField{"Mod_Name", gffResRef, "m01"}))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		readGFFStrings(data, "FirstName", "LastName", "Mod_Name")
+	})
+}
+
+func TestGFFCharacterName(t *testing.T) {
+	name, err := gffCharacterName(buildGFF("BIC ",
+		gffField{"FirstName", gffCExoLocString, "Daelan"},
+		gffField{"LastName", gffCExoLocString, ""},
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "Daelan" {
+		t.Errorf("got %q, want %q", name, "Daelan")
+	}
+}
+
+func TestReadSaveInfo(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string][]byte{
+		"CURRENTMODULE.TXT": []byte("2100_Crossroad_Keep\x00"),
+		"player.bic": buildGFF("BIC ",
+			gffField{"FirstName", gffCExoLocString, "Casavir"},
+			gffField{"LastName", gffCExoLocString, ""},
+		),
+		"playerlist.ifo": buildGFF("IFO ",
+			gffField{"FirstName", gffCExoLocString, "Khelgar"},
+			gffField{"LastName", gffCExoLocString, "Ironfist"},
+		),
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	info := readSaveInfo(dir)
+	want := saveInfo{Character: "Khelgar Ironfist", Module: "2100_Crossroad_Keep"}
+	if info != want {
+		t.Errorf("got %+v, want %+v", info, want)
+	}
+
+	// Without a player list, the character file names the character
+	os.Remove(filepath.Join(dir, "playerlist.ifo"))
+	if info := readSaveInfo(dir); info.Character != "Casavir" {
+		t.Errorf("got character %q, want %q", info.Character, "Casavir")
+	}
+}
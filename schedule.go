@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+const (
+	// sessionPauseReason is the pause reason used outside the configured session windows
+	sessionPauseReason = "outside session hours"
+	// scheduleCheckInterval is how often the session windows are checked
+	scheduleCheckInterval = 30 * time.Second
+)
+
+// ScheduleWindow is a recurring time range on some days of the week.
+// If End is earlier than Start the window runs past midnight into the next day.
+type ScheduleWindow struct {
+	Days  []string `json:"days,omitempty"` // Days the window starts on (e.g., ["mon", "fri"], "weekdays", "weekends"; empty = every day)
+	Start string   `json:"start"`          // Start time, 24-hour "HH:MM"
+	End   string   `json:"end"`            // End time, 24-hour "HH:MM"
+}
+
+// scheduleWindow is a parsed ScheduleWindow
+type scheduleWindow struct {
+	days  [7]bool // Indexed by time.Weekday
+	start int     // Minutes after midnight
+	end   int     // Minutes after midnight
+}
+
+var weekdayNames = map[string][]time.Weekday{
+	"sun": {time.Sunday}, "sunday": {time.Sunday},
+	"mon": {time.Monday}, "monday": {time.Monday},
+	"tue": {time.Tuesday}, "tuesday": {time.Tuesday},
+	"wed": {time.Wednesday}, "wednesday": {time.Wednesday},
+	"thu": {time.Thursday}, "thursday": {time.Thursday},
+	"fri": {time.Friday}, "friday": {time.Friday},
+	"sat": {time.Saturday}, "saturday": {time.Saturday},
+	"weekdays": {time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+	"weekends": {time.Saturday, time.Sunday},
+}
+
+// parseSchedule parses schedule windows from the config. The error names
+// the config setting and the first window that doesn't parse.
+func parseSchedule(setting string, windows []ScheduleWindow) ([]scheduleWindow, error) {
+	var parsed []scheduleWindow
+	for i, w := range windows {
+		sw, err := parseScheduleWindow(w)
+		if err != nil {
+			return nil, fmt.Errorf("%s window %d: %v", setting, i+1, err)
+		}
+		parsed = append(parsed, sw)
+	}
+	return parsed, nil
+}
+
+func parseScheduleWindow(w ScheduleWindow) (scheduleWindow, error) {
+	var sw scheduleWindow
+	var err error
+	if sw.start, err = parseClock(w.Start); err != nil {
+		return sw, fmt.Errorf("start: %v", err)
+	}
+	if sw.end, err = parseClock(w.End); err != nil {
+		return sw, fmt.Errorf("end: %v", err)
+	}
+
+	if len(w.Days) == 0 {
+		for d := range sw.days {
+			sw.days[d] = true
+		}
+		return sw, nil
+	}
+	for _, name := range w.Days {
+		days, ok := weekdayNames[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return sw, fmt.Errorf("unknown day %q", name)
+		}
+		for _, d := range days {
+			sw.days[d] = true
+		}
+	}
+	return sw, nil
+}
+
+// parseClock parses a 24-hour "HH:MM" time into minutes after midnight
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q (expected HH:MM)", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// contains reports whether t falls inside the window
+func (w scheduleWindow) contains(t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+	today := t.Weekday()
+	yesterday := (today + 6) % 7
+
+	switch {
+	case w.start == w.end:
+		// Whole day
+		return w.days[today]
+	case w.start < w.end:
+		return w.days[today] && minute >= w.start && minute < w.end
+	default:
+		// Runs past midnight: the late part belongs to today, the early part to yesterday's window
+		return (w.days[today] && minute >= w.start) || (w.days[yesterday] && minute < w.end)
+	}
+}
+
+// inSchedule reports whether t falls inside any of the windows.
+// An empty schedule places no restriction.
+func inSchedule(windows []scheduleWindow, t time.Time) bool {
+	if len(windows) == 0 {
+		return true
+	}
+	for _, w := range windows {
+		if w.contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// watchSessionWindows pauses alarms outside the configured session windows.
+// When a session starts the alarm timer starts over from zero.
+func (sr *SaveReminder) watchSessionWindows() {
+	ticker := time.NewTicker(scheduleCheckInterval)
+	defer ticker.Stop()
+
+	firstCheck := true
+	wasInSession := false
+	for {
+		inSession := inSchedule(sr.sessionWindows, time.Now())
+		if firstCheck || inSession != wasInSession {
+			if inSession {
+				if !firstCheck {
+					slog.Info("Session window started")
+				}
+				sr.unpauseAlarms(sessionPauseReason, true)
+			} else {
+				if !firstCheck {
+					slog.Info("Session window ended")
+				}
+				sr.pauseAlarms(sessionPauseReason)
+			}
+		}
+		firstCheck = false
+		wasInSession = inSession
+
+		select {
+		case <-sr.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSchedule(t *testing.T) {
+	tests := []struct {
+		name    string
+		windows []ScheduleWindow
+		wantErr string // Part of the error, "" = valid
+	}{
+		{"empty", nil, ""},
+		{"valid", []ScheduleWindow{{Days: []string{"weekdays", "Sat"}, Start: "18:00", End: "23:30"}}, ""},
+		{"bad start", []ScheduleWindow{{Start: "6pm", End: "23:00"}}, "session_windows window 1: start: invalid time"},
+		{"bad end", []ScheduleWindow{{Start: "18:00", End: "23:00"}, {Start: "18:00", End: "24:30"}}, "session_windows window 2: end: invalid time"},
+		{"unknown day", []ScheduleWindow{{Days: []string{"funday"}, Start: "18:00", End: "23:00"}}, `unknown day "funday"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			windows, err := parseSchedule("session_windows", tt.windows)
+			if tt.wantErr == "" {
+				if err != nil || len(windows) != len(tt.windows) {
+					t.Errorf("parseSchedule = %d windows, error %v, want %d windows", len(windows), err, len(tt.windows))
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("parseSchedule error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestScheduleWindowContains(t *testing.T) {
+	window := func(start, end string, days ...string) scheduleWindow {
+		windows, err := parseSchedule("test", []ScheduleWindow{{Days: days, Start: start, End: end}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return windows[0]
+	}
+	// 2024-03-01 is a Friday
+	at := func(day int, clock string) time.Time {
+		t, err := time.ParseInLocation("2006-01-02 15:04", fmt.Sprintf("2024-03-%02d %s", day, clock), time.Local)
+		if err != nil {
+			panic(err)
+		}
+		return t
+	}
+	const fri, sat, sun, mon = 1, 2, 3, 4
+
+	evening := window("18:00", "23:00", "fri")
+	lateFriday := window("22:00", "02:00", "fri")
+	lateSaturday := window("22:00", "02:00", "sat")
+	lateSunday := window("22:00", "02:00", "sun")
+	wholeWeekend := window("00:00", "00:00", "weekends")
+	tests := []struct {
+		name   string
+		window scheduleWindow
+		t      time.Time
+		want   bool
+	}{
+		{"inside", evening, at(fri, "20:00"), true},
+		{"at the start", evening, at(fri, "18:00"), true},
+		{"at the end", evening, at(fri, "23:00"), false},
+		{"other day", evening, at(sat, "20:00"), false},
+		{"past midnight, late part", lateFriday, at(fri, "23:30"), true},
+		{"past midnight, early part next day", lateFriday, at(sat, "01:00"), true},
+		{"past midnight, after the end", lateFriday, at(sat, "02:00"), false},
+		{"past midnight, early part of the start day", lateFriday, at(fri, "01:00"), false},
+		{"Saturday night into Sunday", lateSaturday, at(sun, "01:59"), true},
+		{"Saturday window on Sunday night", lateSaturday, at(sun, "23:00"), false},
+		{"Sunday night into Monday across the week", lateSunday, at(mon, "01:00"), true},
+		{"Sunday window early on Sunday", lateSunday, at(sun, "01:00"), false},
+		{"whole day", wholeWeekend, at(sat, "00:00"), true},
+		{"whole day, late", wholeWeekend, at(sun, "23:59"), true},
+		{"whole day, not a weekend day", wholeWeekend, at(mon, "12:00"), false},
+		{"whole day doesn't run past midnight", wholeWeekend, at(fri, "23:59"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.window.contains(tt.t); got != tt.want {
+				t.Errorf("contains(%s) = %v, want %v", tt.t.Format("Mon 15:04"), got, tt.want)
+			}
+		})
+	}
+
+	if !inSchedule(nil, at(mon, "03:00")) {
+		t.Error("an empty schedule must not restrict anything")
+	}
+	if !inSchedule([]scheduleWindow{evening, lateSunday}, at(mon, "01:00")) || inSchedule([]scheduleWindow{evening, lateSunday}, at(mon, "12:00")) {
+		t.Error("inSchedule must match any of its windows and nothing else")
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// fullSnapshotLabel marks backups of the whole NWN2 user folder
+	fullSnapshotLabel = "full snapshot"
+	// fullSnapshotStartupDelay keeps an overdue snapshot from competing with the game's startup
+	fullSnapshotStartupDelay = time.Minute
+)
+
+// FullSnapshotConfig sets up snapshots of the NWN2 user folder beyond the quicksave slot
+type FullSnapshotConfig struct {
+	Interval   string   `json:"interval"`    // Time between snapshots (e.g., "6h", empty = no scheduled snapshots)
+	OnShutdown bool     `json:"on_shutdown"` // Also take a snapshot when the application exits
+	Paths      []string `json:"paths"`       // Files and folders to include, relative to the NWN2 user folder
+}
+
+// defaultFullSnapshotPaths are the character vaults, all saves, custom content and settings
+func defaultFullSnapshotPaths() []string {
+	return []string{"localvault", "servervault", "saves", "override", "nwn2.ini", "nwn2player.ini", "nwnplayer.ini"}
+}
+
+// nwn2UserFolder is the game's folder in Documents, which holds the saves,
+// the character vaults and the ini files
+func nwn2UserFolder(savesPath string) string {
+	return filepath.Dir(filepath.Dir(savesPath))
+}
+
+// runFullSnapshots takes a full snapshot every interval. The schedule
+// continues from the last snapshot, so restarting the application doesn't
+// postpone it; an overdue snapshot is taken shortly after startup.
+func (sr *SaveReminder) runFullSnapshots(interval time.Duration) {
+	wait := fullSnapshotStartupDelay
+	if last, ok := sr.lastFullSnapshot(); ok && time.Until(last.Add(interval)) > wait {
+		wait = time.Until(last.Add(interval))
+	}
+	slog.Info("Next full snapshot scheduled", "at", time.Now().Add(wait).Format("2006-01-02 15:04:05"))
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	for {
+		select {
+		case <-sr.done:
+			return
+		case <-timer.C:
+		}
+		if _, err := sr.takeFullSnapshot(false); err != nil {
+			slog.Error("Full snapshot failed", "error", err)
+		}
+		timer.Reset(interval)
+	}
+}
+
+// lastFullSnapshot returns when the newest full snapshot was taken
+func (sr *SaveReminder) lastFullSnapshot() (time.Time, bool) {
+	backups, err := listBackups(sr.backupsPath)
+	if err != nil {
+		return time.Time{}, false
+	}
+	for _, b := range backups {
+		if b.Label == fullSnapshotLabel {
+			return b.Time, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// takeFullSnapshot copies the configured paths into a new "full snapshot"
+// backup and returns its name. Unless force is set, nothing is copied when no
+// file changed since the last snapshot.
+func (sr *SaveReminder) takeFullSnapshot(force bool) (string, error) {
+	sr.snapshotMu.Lock()
+	defer sr.snapshotMu.Unlock()
+
+	userFolder := nwn2UserFolder(sr.savesPath)
+	exclude := sr.snapshotExcludes()
+	var sources []string
+	for _, path := range sr.config.FullSnapshot.Paths {
+		src := filepath.Join(userFolder, path)
+		if _, err := os.Stat(src); err != nil {
+			slog.Debug("Skipping full snapshot path", "path", src, "error", err)
+			continue
+		}
+		sources = append(sources, path)
+	}
+	if len(sources) == 0 {
+		return "", fmt.Errorf("none of the full snapshot paths exist in %s", userFolder)
+	}
+
+	if !force {
+		if last, ok := sr.lastFullSnapshot(); ok {
+			newest := time.Time{}
+			for _, path := range sources {
+				if t := newestModTime(filepath.Join(userFolder, path), exclude); t.After(newest) {
+					newest = t
+				}
+			}
+			if !newest.After(last) {
+				slog.Info("Nothing changed since the last full snapshot, skipping it", "last", last.Format("2006-01-02 15:04:05"))
+				return "", nil
+			}
+		}
+	}
+
+	name := fmt.Sprintf("%s - %s", time.Now().Format(backupTimestampFormat), fullSnapshotLabel)
+	partial := filepath.Join(sr.backupsPath, name+partialSuffix)
+	started := time.Now()
+	for _, path := range sources {
+		if err := copySnapshotPath(filepath.Join(userFolder, path), filepath.Join(partial, path), exclude); err != nil {
+			os.RemoveAll(partial)
+			return "", err
+		}
+	}
+	dest := filepath.Join(sr.backupsPath, name)
+	if err := os.Rename(partial, dest); err != nil {
+		os.RemoveAll(partial)
+		return "", fmt.Errorf("error finishing full snapshot: %v", err)
+	}
+	slog.Info("Full snapshot created", "path", dest, "paths", strings.Join(sources, ", "), "took", time.Since(started).Round(time.Millisecond))
+	sr.backupAdded(dest)
+
+	if sr.replicator != nil {
+		sr.replicator.enqueue(name)
+	}
+	sr.pruneBackups()
+	return name, nil
+}
+
+// snapshotExcludes lists folders a snapshot of the saves must not include:
+// the backups themselves and the git backup repository
+func (sr *SaveReminder) snapshotExcludes() []string {
+	exclude := []string{sr.backupsPath}
+	if sr.gitBackup != nil {
+		exclude = append(exclude, sr.gitBackup.path)
+	}
+	return exclude
+}
+
+// isExcluded reports whether path is one of the excluded folders
+func isExcluded(path string, exclude []string) bool {
+	for _, e := range exclude {
+		if filepath.Clean(path) == filepath.Clean(e) {
+			return true
+		}
+	}
+	return false
+}
+
+// newestModTime returns the newest modification time of a file, or of any
+// file in a folder and its subfolders
+func newestModTime(root string, exclude []string) time.Time {
+	var newest time.Time
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if isExcluded(path, exclude) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info, err := d.Info(); err == nil && info.ModTime().After(newest) {
+			newest = info.ModTime()
+		}
+		return nil
+	})
+	return newest
+}
+
+// copySnapshotPath copies a file, or a folder and everything in it except the excluded folders
+func copySnapshotPath(src, dst string, exclude []string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return fmt.Errorf("error reading %s: %v", path, err)
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		switch {
+		case d.IsDir():
+			if isExcluded(path, exclude) {
+				return filepath.SkipDir
+			}
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return fmt.Errorf("error creating %s: %v", target, err)
+			}
+		case d.Type().IsRegular():
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return fmt.Errorf("error creating %s: %v", filepath.Dir(target), err)
+			}
+			return copyFile(path, target)
+		}
+		return nil
+	})
+}
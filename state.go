@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// defaultStateFile is the state file used when state_file is not set
+	defaultStateFile = "state.json"
+	// stateSaveInterval is how often the state is saved while nothing happens,
+	// so time spent paused isn't lost in a crash
+	stateSaveInterval = time.Minute
+)
+
+// reminderState is what the reminder needs to carry on after a restart
+type reminderState struct {
+	Updated       time.Time `json:"updated"`        // When the state was saved
+	LastSave      time.Time `json:"last_save"`      // Last save, moved forward by time spent paused
+	SnoozeUntil   time.Time `json:"snooze_until"`   // End of the current snooze (zero = not snoozed)
+	Acknowledged  bool      `json:"acknowledged"`   // Alarms are off until the next save
+	PendingBackup time.Time `json:"pending_backup"` // When an unprocessed quicksave change was seen (zero = none)
+	Running       bool      `json:"running"`        // False after a clean shutdown
+}
+
+// resolveStatePath returns the state file path from the config, or "" if it is disabled
+func resolveStatePath(config Config) string {
+	switch config.StateFile {
+	case "none":
+		return ""
+	case "":
+		return resolveExePath(defaultStateFile)
+	}
+	return resolveExePath(config.StateFile)
+}
+
+// loadState reads the state file. A missing file is not an error; ok is false then.
+func loadState(path string) (state reminderState, ok bool, err error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, false, nil
+	}
+	if err != nil {
+		return state, false, fmt.Errorf("error reading state file: %v", err)
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return state, false, fmt.Errorf("invalid state file %s: %v", path, err)
+	}
+	return state, true, nil
+}
+
+// saveState writes the current state to the state file. It is replaced
+// atomically, so a crash while writing leaves the previous state intact.
+func (sr *SaveReminder) saveState() {
+	if sr.statePath == "" {
+		return
+	}
+
+	sr.mu.Lock()
+	now := time.Now()
+	state := reminderState{
+		Updated:       now,
+		LastSave:      sr.lastSaveTime,
+		SnoozeUntil:   sr.snoozeUntil,
+		Acknowledged:  sr.acknowledged,
+		PendingBackup: sr.pendingBackup,
+		Running:       !sr.stopped,
+	}
+	// Time paused so far doesn't count, just as when the pause is lifted
+	var pausedSince time.Time
+	for _, since := range sr.paused {
+		if pausedSince.IsZero() || since.Before(pausedSince) {
+			pausedSince = since
+		}
+	}
+	if !pausedSince.IsZero() {
+		state.LastSave = state.LastSave.Add(now.Sub(pausedSince))
+	}
+	sr.mu.Unlock()
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		slog.Warn("Could not encode state", "error", err)
+		return
+	}
+	sr.stateMu.Lock()
+	defer sr.stateMu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(sr.statePath), 0755); err != nil {
+		slog.Warn("Could not save state", "error", err)
+		return
+	}
+	if err := writeFileAtomic(sr.statePath, data); err != nil {
+		slog.Warn("Could not save state", "error", err)
+	}
+}
+
+// runStateSaver saves the state every stateSaveInterval until shutdown
+func (sr *SaveReminder) runStateSaver() {
+	ticker := time.NewTicker(stateSaveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-sr.done:
+			return
+		case <-ticker.C:
+			sr.saveState()
+		}
+	}
+}
+
+// restoreState carries on from the saved state: the last save (unless a
+// newer one was found at startup), a snooze that hasn't ended yet and an acknowledged
+// alarm. The escalation step isn't saved: it follows from the restored time
+// since the last save, so the next alarm carries on at the same step. After
+// a clean shutdown the time the application wasn't running doesn't count,
+// like a pause; after a crash it does, as the game may have kept running.
+// It reports whether a quicksave change was still waiting to be backed up.
+func (sr *SaveReminder) restoreState(state reminderState) (pendingBackup bool) {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	if !state.Running && time.Now().After(state.Updated) {
+		state.LastSave = state.LastSave.Add(time.Since(state.Updated))
+	}
+	if state.LastSave.After(sr.lastSaveTime) && !state.LastSave.After(time.Now()) {
+		sr.lastSaveTime = state.LastSave
+	}
+	if time.Now().Before(state.SnoozeUntil) {
+		sr.snoozeUntil = state.SnoozeUntil
+	}
+	// An acknowledge only lasts until the next save
+	saveSinceState := !sr.lastSaveTime.Equal(state.LastSave)
+	if state.Acknowledged && !saveSinceState {
+		sr.acknowledged = true
+	}
+
+	slog.Info("Resumed saved state",
+		"saved", state.Updated.Format("2006-01-02 15:04:05"),
+		"clean_shutdown", !state.Running,
+		"last_save", sr.lastSaveTime.Format("2006-01-02 15:04:05"),
+		"snoozed_until", formatStateTime(sr.snoozeUntil),
+		"acknowledged", sr.acknowledged)
+	return !state.PendingBackup.IsZero()
+}
+
+// formatStateTime formats a time for the log, or "-" if it isn't set
+func formatStateTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Format("15:04:05")
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRestoreState(t *testing.T) {
+	now := time.Now()
+	ago := func(d time.Duration) time.Time { return now.Add(-d) }
+	// near reports whether two times are within a second of each other
+	near := func(a, b time.Time) bool { return a.Sub(b).Abs() < time.Second }
+
+	tests := []struct {
+		name         string
+		found        time.Time // Last save found at startup
+		state        reminderState
+		lastSave     time.Time
+		snoozeUntil  time.Time
+		acknowledged bool
+		pending      bool
+	}{
+		{
+			name:     "clean shutdown leaves out the downtime",
+			state:    reminderState{Updated: ago(20 * time.Minute), LastSave: ago(30 * time.Minute)},
+			lastSave: ago(10 * time.Minute),
+		},
+		{
+			name:     "crash counts the downtime",
+			state:    reminderState{Updated: ago(20 * time.Minute), LastSave: ago(30 * time.Minute), Running: true},
+			lastSave: ago(30 * time.Minute),
+		},
+		{
+			name:     "newer save found at startup wins",
+			found:    ago(5 * time.Minute),
+			state:    reminderState{Updated: ago(20 * time.Minute), LastSave: ago(30 * time.Minute), Running: true},
+			lastSave: ago(5 * time.Minute),
+		},
+		{
+			name:        "snooze still running",
+			state:       reminderState{Updated: ago(time.Minute), LastSave: ago(10 * time.Minute), SnoozeUntil: now.Add(5 * time.Minute), Running: true},
+			lastSave:    ago(10 * time.Minute),
+			snoozeUntil: now.Add(5 * time.Minute),
+		},
+		{
+			name:     "snooze already over",
+			state:    reminderState{Updated: ago(10 * time.Minute), LastSave: ago(20 * time.Minute), SnoozeUntil: ago(time.Minute), Running: true},
+			lastSave: ago(20 * time.Minute),
+		},
+		{
+			name:         "acknowledge kept until the next save",
+			state:        reminderState{Updated: ago(time.Minute), LastSave: ago(40 * time.Minute), Acknowledged: true, Running: true},
+			lastSave:     ago(40 * time.Minute),
+			acknowledged: true,
+		},
+		{
+			name:     "acknowledge ends with a newer save",
+			found:    ago(2 * time.Minute),
+			state:    reminderState{Updated: ago(time.Minute), LastSave: ago(40 * time.Minute), Acknowledged: true, Running: true},
+			lastSave: ago(2 * time.Minute),
+		},
+		{
+			name:     "quicksave change waiting for a backup",
+			state:    reminderState{Updated: ago(time.Minute), LastSave: ago(10 * time.Minute), PendingBackup: ago(time.Minute), Running: true},
+			lastSave: ago(10 * time.Minute),
+			pending:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sr := &SaveReminder{lastSaveTime: tt.found}
+			pending := sr.restoreState(tt.state)
+			if !near(sr.lastSaveTime, tt.lastSave) {
+				t.Errorf("last save = %v, want %v", sr.lastSaveTime, tt.lastSave)
+			}
+			if !near(sr.snoozeUntil, tt.snoozeUntil) {
+				t.Errorf("snoozed until %v, want %v", sr.snoozeUntil, tt.snoozeUntil)
+			}
+			if sr.acknowledged != tt.acknowledged {
+				t.Errorf("acknowledged = %v, want %v", sr.acknowledged, tt.acknowledged)
+			}
+			if pending != tt.pending {
+				t.Errorf("pending backup = %v, want %v", pending, tt.pending)
+			}
+		})
+	}
+}
+
+func TestStateSurvivesRestart(t *testing.T) {
+	config := Config{
+		AlarmInterval:   "5m",
+		RepeatInterval:  "5m",
+		AlarmEscalation: []EscalationStep{{After: "10m", Repeat: "2m"}, {After: "20m", Repeat: "1m"}},
+	}
+	path := filepath.Join(t.TempDir(), defaultStateFile)
+	before := &SaveReminder{
+		config:       config,
+		escalation:   buildEscalation(config),
+		statePath:    path,
+		lastSaveTime: time.Now().Add(-15 * time.Minute),
+		stopped:      true,
+	}
+	step := before.stageFor(time.Since(before.lastSaveTime)).index
+	before.saveState()
+
+	state, ok, err := loadState(path)
+	if err != nil || !ok {
+		t.Fatalf("loadState = %v, %v", ok, err)
+	}
+	// The application was closed for an hour
+	state.Updated = state.Updated.Add(-time.Hour)
+	state.LastSave = state.LastSave.Add(-time.Hour)
+
+	after := &SaveReminder{config: config, escalation: buildEscalation(config)}
+	after.restoreState(state)
+	if elapsed := time.Since(after.lastSaveTime); elapsed < 15*time.Minute-time.Second || elapsed > 15*time.Minute+time.Second {
+		t.Errorf("time since the last save after a clean restart = %v, want 15m0s", elapsed)
+	}
+	// The escalation step carries on from the restored time since the last save
+	if got := after.stageFor(time.Since(after.lastSaveTime)).index; got != step || step != 1 {
+		t.Errorf("escalation step after restart = %d, want %d (was 1 before)", got, step)
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/gopxl/beep"
+)
+
+// toneAmplitude keeps generated tones below full scale; square waves at full
+// scale are much louder than typical alarm sound files
+const toneAmplitude = 0.5
+
+// toneRamp is the fade applied to each tone's start and end to avoid clicks
+const toneRamp = 5 * time.Millisecond
+
+// The whole tone is rendered in memory before it plays, so its length is
+// capped; alarm_loop is the way to keep an alarm going
+const (
+	maxToneRepeat = 10               // Most times alarm_tone.repeat may play the pattern
+	maxToneStep   = 10 * time.Second // Longest duration or pause of a single step
+	maxToneLength = time.Minute      // Longest whole tone, repeats included
+)
+
+// ToneConfig describes the built-in alarm tone played when no sound file is set
+type ToneConfig struct {
+	Pattern []ToneStep `json:"pattern"` // Tones played in order
+	Repeat  int        `json:"repeat"`  // How many times to play the pattern (default: 1, at most maxToneRepeat)
+}
+
+// ToneStep is a single tone (or a rest) in a tone pattern
+type ToneStep struct {
+	Frequency float64 `json:"frequency"`          // Pitch in Hz (0 = silence)
+	Duration  string  `json:"duration"`           // Length of the tone (e.g., "200ms")
+	Pause     string  `json:"pause,omitempty"`    // Silence after the tone (e.g., "100ms")
+	Waveform  string  `json:"waveform,omitempty"` // "sine" (default), "square", "triangle" or "sawtooth"
+}
+
+// defaultTone returns the default alarm tone: two rising triple beeps
+func defaultTone() ToneConfig {
+	return ToneConfig{
+		Pattern: []ToneStep{
+			{Frequency: 880, Duration: "150ms", Pause: "80ms", Waveform: "sine"},
+			{Frequency: 988, Duration: "150ms", Pause: "80ms", Waveform: "sine"},
+			{Frequency: 1175, Duration: "250ms", Pause: "400ms", Waveform: "sine"},
+		},
+		Repeat: 2,
+	}
+}
+
+// waveforms map a phase in [0, 1) to a sample in [-1, 1]
+var waveforms = map[string]func(phase float64) float64{
+	"sine": func(phase float64) float64 {
+		return math.Sin(2 * math.Pi * phase)
+	},
+	"square": func(phase float64) float64 {
+		if phase < 0.5 {
+			return 1
+		}
+		return -1
+	},
+	"triangle": func(phase float64) float64 {
+		return 1 - 4*math.Abs(phase-0.5)
+	},
+	"sawtooth": func(phase float64) float64 {
+		return 2*phase - 1
+	},
+}
+
+// toneSegment is a parsed ToneStep
+type toneSegment struct {
+	frequency float64
+	samples   int // Tone length in samples
+	pause     int // Silence after the tone in samples
+	wave      func(phase float64) float64
+}
+
+// parseTone validates a tone config and converts it to segments at the mixer sample rate
+func parseTone(tone ToneConfig) ([]toneSegment, error) {
+	if len(tone.Pattern) == 0 {
+		return nil, fmt.Errorf("tone pattern is empty")
+	}
+	if tone.Repeat > maxToneRepeat {
+		return nil, fmt.Errorf("repeat %d is more than %d", tone.Repeat, maxToneRepeat)
+	}
+
+	var length time.Duration
+	segments := make([]toneSegment, 0, len(tone.Pattern))
+	for i, step := range tone.Pattern {
+		duration, err := time.ParseDuration(step.Duration)
+		if err != nil || duration <= 0 {
+			return nil, fmt.Errorf("tone step %d: invalid duration %q", i+1, step.Duration)
+		}
+		if duration > maxToneStep {
+			return nil, fmt.Errorf("tone step %d: duration %v is longer than %v", i+1, duration, maxToneStep)
+		}
+		var pause time.Duration
+		if step.Pause != "" {
+			if pause, err = time.ParseDuration(step.Pause); err != nil || pause < 0 {
+				return nil, fmt.Errorf("tone step %d: invalid pause %q", i+1, step.Pause)
+			}
+			if pause > maxToneStep {
+				return nil, fmt.Errorf("tone step %d: pause %v is longer than %v", i+1, pause, maxToneStep)
+			}
+		}
+		length += duration + pause
+		if step.Frequency < 0 || step.Frequency > 20000 {
+			return nil, fmt.Errorf("tone step %d: frequency %v Hz is out of range (0-20000)", i+1, step.Frequency)
+		}
+		waveform := strings.ToLower(step.Waveform)
+		if waveform == "" {
+			waveform = "sine"
+		}
+		wave, ok := waveforms[waveform]
+		if !ok {
+			return nil, fmt.Errorf("tone step %d: unknown waveform %q (use sine, square, triangle or sawtooth)", i+1, step.Waveform)
+		}
+
+		segments = append(segments, toneSegment{
+			frequency: step.Frequency,
+			samples:   mixerSampleRate.N(duration),
+			pause:     mixerSampleRate.N(pause),
+			wave:      wave,
+		})
+	}
+	if total := length * time.Duration(max(tone.Repeat, 1)); total > maxToneLength {
+		return nil, fmt.Errorf("tone is %v long with its repeats, more than %v", total, maxToneLength)
+	}
+	return segments, nil
+}
+
+// toneStreamer generates the tone pattern the given number of times
+func toneStreamer(segments []toneSegment, repeat int) beep.Streamer {
+	if repeat < 1 {
+		repeat = 1
+	}
+	ramp := mixerSampleRate.N(toneRamp)
+
+	streamers := make([]beep.Streamer, 0, len(segments)*repeat)
+	for r := 0; r < repeat; r++ {
+		for _, seg := range segments {
+			seg := seg
+			pos := 0
+			total := seg.samples + seg.pause
+			streamers = append(streamers, beep.StreamerFunc(func(samples [][2]float64) (n int, ok bool) {
+				for i := range samples {
+					if pos >= total {
+						return i, i > 0
+					}
+					var v float64
+					if pos < seg.samples && seg.frequency > 0 {
+						phase := math.Mod(float64(pos)*seg.frequency/float64(mixerSampleRate), 1)
+						v = seg.wave(phase) * toneAmplitude
+						// Linear fade in/out so the tone doesn't click
+						if pos < ramp {
+							v *= float64(pos) / float64(ramp)
+						} else if left := seg.samples - pos; left < ramp {
+							v *= float64(left) / float64(ramp)
+						}
+					}
+					samples[i] = [2]float64{v, v}
+					pos++
+				}
+				return len(samples), true
+			}))
+		}
+	}
+	return beep.Seq(streamers...)
+}
+
+// renderTone renders a tone config once, when the config loads, so alarms
+// don't generate it again and its loudness can be measured like a sound file's
+func renderTone(tone ToneConfig) (*sound, error) {
+	segments, err := parseTone(tone)
+	if err != nil {
+		return nil, err
+	}
+	buffer := beep.NewBuffer(mixerFormat)
+	buffer.Append(toneStreamer(segments, tone.Repeat))
+	return newSound(buffer), nil
+}
+
+// playTone starts the built-in alarm tone and returns its playback.
+// It returns nil if the tone is invalid or no audio device is available.
+func (sr *SaveReminder) playTone(volume int) *playback {
+	if sr.tone == nil {
+		return nil
+	}
+	if err := sr.audio.available(); err != nil {
+		slog.Warn("Cannot play alarm tone", "error", err)
+		return nil
+	}
+
+	slog.Info("Playing alarm tone", "volume", volume)
+	p, err := sr.audio.startSound(sr.tone, volume, sr.playbackOptions())
+	if err != nil {
+		slog.Warn("Cannot play alarm tone", "error", err)
+		return nil
+	}
+	return p
+}
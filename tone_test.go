@@ -0,0 +1,66 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gopxl/beep"
+)
+
+func TestParseTone(t *testing.T) {
+	step := func(duration, pause string) ToneStep {
+		return ToneStep{Frequency: 440, Duration: duration, Pause: pause}
+	}
+	tests := []struct {
+		name    string
+		tone    ToneConfig
+		wantErr string // Part of the error, "" = valid
+	}{
+		{"default tone", defaultTone(), ""},
+		{"empty pattern", ToneConfig{}, "empty"},
+		{"longest step", ToneConfig{Pattern: []ToneStep{step("10s", "10s")}}, ""},
+		{"step too long", ToneConfig{Pattern: []ToneStep{step("10h", "")}}, "duration 10h0m0s is longer"},
+		{"pause too long", ToneConfig{Pattern: []ToneStep{step("1s", "11s")}}, "pause 11s is longer"},
+		{"zero duration", ToneConfig{Pattern: []ToneStep{step("0s", "")}}, "invalid duration"},
+		{"negative pause", ToneConfig{Pattern: []ToneStep{step("1s", "-1s")}}, "invalid pause"},
+		{"too many repeats", ToneConfig{Pattern: []ToneStep{step("1s", "")}, Repeat: 11}, "repeat 11"},
+		{"longest tone", ToneConfig{Pattern: []ToneStep{step("3s", "3s")}, Repeat: 10}, ""},
+		{"tone too long with repeats", ToneConfig{Pattern: []ToneStep{step("4s", "3s")}, Repeat: 10}, "1m10s long"},
+		{"unknown waveform", ToneConfig{Pattern: []ToneStep{{Frequency: 440, Duration: "1s", Waveform: "noise"}}}, "unknown waveform"},
+		{"frequency out of range", ToneConfig{Pattern: []ToneStep{{Frequency: 30000, Duration: "1s"}}}, "out of range"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseTone(tt.tone)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("parseTone error = %v, want none", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("parseTone error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestToneStreamerLength(t *testing.T) {
+	tone := ToneConfig{Pattern: []ToneStep{
+		{Frequency: 440, Duration: "200ms", Pause: "100ms"},
+		{Frequency: 0, Duration: "50ms"},
+	}}
+	segments, err := parseTone(tone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pattern := mixerSampleRate.N(200*time.Millisecond) + mixerSampleRate.N(100*time.Millisecond) + mixerSampleRate.N(50*time.Millisecond)
+	for _, repeat := range []int{0, 1, 3} {
+		buffer := beep.NewBuffer(mixerFormat)
+		buffer.Append(toneStreamer(segments, repeat))
+		if want := pattern * max(repeat, 1); buffer.Len() != want {
+			t.Errorf("repeat %d: %d samples, want %d", repeat, buffer.Len(), want)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"log/slog"
+	"math"
+	"os"
+	"os/exec"
+	"runtime"
+	"time"
+
+	"fyne.io/systray"
+)
+
+// trayState is the reminder state shown by the tray icon color
+type trayState int
+
+const (
+	trayStateSaved   trayState = iota // Recently saved (green)
+	trayStateDueSoon                  // Next alarm is close (amber)
+	trayStateOverdue                  // Alarm is going off (red)
+	trayStatePaused                   // Paused, snoozed or acknowledged (grey)
+)
+
+// trayDueSoon is how close the next alarm has to be for the icon to turn amber
+const trayDueSoon = time.Minute
+
+var trayColors = map[trayState]color.RGBA{
+	trayStateSaved:   {R: 0x2e, G: 0xb8, B: 0x4b, A: 0xff},
+	trayStateDueSoon: {R: 0xf0, G: 0xa2, B: 0x1c, A: 0xff},
+	trayStateOverdue: {R: 0xd9, G: 0x30, B: 0x25, A: 0xff},
+	trayStatePaused:  {R: 0x8c, G: 0x8c, B: 0x8c, A: 0xff},
+}
+
+// trayAvailable reports whether a system tray can be shown on this system
+func trayAvailable() error {
+	if runtime.GOOS == "linux" && os.Getenv("DBUS_SESSION_BUS_ADDRESS") == "" {
+		// The StatusNotifierItem protocol needs a D-Bus session
+		return fmt.Errorf("no D-Bus session bus (DBUS_SESSION_BUS_ADDRESS is not set)")
+	}
+	return nil
+}
+
+// runTray shows the tray icon and blocks until Quit is chosen or stop receives a signal
+func (sr *SaveReminder) runTray(stop <-chan os.Signal) {
+	systray.Run(func() {
+		sr.setupTray(stop)
+	}, nil)
+}
+
+// setupTray builds the tray menu and starts updating the icon and tooltip
+func (sr *SaveReminder) setupTray(stop <-chan os.Signal) {
+	systray.SetTitle("NWN2 Save Reminder")
+	status := systray.AddMenuItem("Starting...", "")
+	status.Disable()
+	systray.AddSeparator()
+	snooze := systray.AddMenuItem(fmt.Sprintf("Snooze %s", sr.config.SnoozeDuration), "Silence alarms for a while")
+	acknowledge := systray.AddMenuItem("Acknowledge alarm", "Stop alarms until the next save")
+	checkpoint := systray.AddMenuItem("Create checkpoint", "Back up the quicksave now as a pinned checkpoint")
+	systray.AddSeparator()
+	openBackups := systray.AddMenuItem("Open backups folder", sr.backupsPath)
+	restoreLatest := systray.AddMenuItem("Restore latest backup", "Replace the quicksave with the newest backup")
+	openConfig := systray.AddMenuItem("Open config", getConfigPath())
+	var console *systray.MenuItem
+	if consoleWindowSupported() {
+		console = systray.AddMenuItem("Show console", "Show or hide the log window")
+	} else {
+		console = &systray.MenuItem{ClickedCh: make(chan struct{})}
+	}
+	systray.AddSeparator()
+	quit := systray.AddMenuItem("Quit", "Exit NWN2 Save Reminder")
+
+	// Keep the taskbar clean: the console is only shown on request
+	consoleVisible := !hideConsoleWindow()
+	if consoleVisible {
+		console.SetTitle("Hide console")
+	}
+
+	ticker := time.NewTicker(time.Second)
+	lastState := trayState(-1)
+	update := func() {
+		state, text := sr.trayStatus()
+		if state != lastState {
+			systray.SetIcon(trayIcon(trayColors[state]))
+			lastState = state
+		}
+		systray.SetTooltip("NWN2 Save Reminder\n" + text)
+		status.SetTitle(text)
+	}
+	update()
+
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				update()
+			case <-snooze.ClickedCh:
+				reply, _ := sr.handleControlCommand("snooze", "tray")
+				status.SetTitle(reply)
+			case <-acknowledge.ClickedCh:
+				sr.handleControlCommand("ack", "tray")
+				update()
+			case <-checkpoint.ClickedCh:
+				reply, _ := sr.handleControlCommand("checkpoint", "tray")
+				status.SetTitle(reply)
+			case <-openBackups.ClickedCh:
+				openPath(sr.backupsPath)
+			case <-restoreLatest.ClickedCh:
+				if err := sr.restoreLatestBackup(); err != nil {
+					slog.Error("Restoring backup failed", "error", err)
+				}
+			case <-openConfig.ClickedCh:
+				openPath(getConfigPath())
+			case <-console.ClickedCh:
+				consoleVisible = !consoleVisible
+				showConsoleWindow(consoleVisible)
+				if consoleVisible {
+					console.SetTitle("Hide console")
+				} else {
+					console.SetTitle("Show console")
+				}
+			case <-quit.ClickedCh:
+				systray.Quit()
+				return
+			case <-stop:
+				systray.Quit()
+				return
+			}
+		}
+	}()
+}
+
+// trayStatus returns the icon state and a one-line description with a countdown
+func (sr *SaveReminder) trayStatus() (trayState, string) {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	sinceSave := formatCountdown(time.Since(sr.lastSaveTime))
+	switch {
+	case len(sr.paused) > 0:
+		return trayStatePaused, fmt.Sprintf("Paused (%s)", sr.pauseReasonsLocked())
+	case sr.acknowledged:
+		return trayStatePaused, fmt.Sprintf("Acknowledged - last save %s ago", sinceSave)
+	case !sr.snoozeUntil.IsZero() && time.Now().Before(sr.snoozeUntil):
+		return trayStatePaused, fmt.Sprintf("Snoozed for %s - last save %s ago", formatCountdown(time.Until(sr.snoozeUntil)), sinceSave)
+	case sr.alarmActive:
+		return trayStateOverdue, fmt.Sprintf("Overdue - last save %s ago", sinceSave)
+	case sr.nextAlarmAt.IsZero():
+		return trayStateSaved, fmt.Sprintf("Last save %s ago", sinceSave)
+	}
+
+	untilAlarm := time.Until(sr.nextAlarmAt)
+	text := fmt.Sprintf("Next alarm in %s", formatCountdown(untilAlarm))
+	if untilAlarm <= trayDueSoon {
+		return trayStateDueSoon, text
+	}
+	return trayStateSaved, text
+}
+
+// formatCountdown formats a duration as m:ss, or h:mm:ss from an hour up
+func formatCountdown(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	total := int(d.Round(time.Second).Seconds())
+	if total >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", total/3600, total/60%60, total%60)
+	}
+	return fmt.Sprintf("%d:%02d", total/60, total%60)
+}
+
+// trayIcon draws a filled circle in the given color.
+// Windows needs an .ico file; other platforms take a PNG.
+func trayIcon(c color.RGBA) []byte {
+	const size = 32
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	center := float64(size-1) / 2
+	radius := float64(size)/2 - 1
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			dx, dy := float64(x)-center, float64(y)-center
+			// Soft 1px edge so the circle doesn't look jagged
+			alpha := radius + 0.5 - math.Hypot(dx, dy)
+			if alpha <= 0 {
+				continue
+			}
+			if alpha > 1 {
+				alpha = 1
+			}
+			img.SetRGBA(x, y, color.RGBA{R: c.R, G: c.G, B: c.B, A: uint8(alpha * 255)})
+		}
+	}
+
+	var pngData bytes.Buffer
+	png.Encode(&pngData, img)
+	if runtime.GOOS != "windows" {
+		return pngData.Bytes()
+	}
+
+	// An .ico holding a single PNG image (supported since Windows Vista)
+	var ico bytes.Buffer
+	binary.Write(&ico, binary.LittleEndian, []uint16{0, 1, 1})                   // Reserved, type (icon), image count
+	ico.Write([]byte{size, size, 0, 0})                                          // Width, height, palette size, reserved
+	binary.Write(&ico, binary.LittleEndian, []uint16{1, 32})                     // Color planes, bits per pixel
+	binary.Write(&ico, binary.LittleEndian, []uint32{uint32(pngData.Len()), 22}) // Data size, data offset
+	ico.Write(pngData.Bytes())
+	return ico.Bytes()
+}
+
+// openPath opens a file or folder with the desktop's default application
+func openPath(path string) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "windows":
+		cmd = exec.Command("explorer", path)
+	case "darwin":
+		cmd = exec.Command("open", path)
+	default:
+		cmd = exec.Command("xdg-open", path)
+	}
+	// Explorer exits with status 1 even on success, so only start errors matter
+	if err := cmd.Start(); err != nil {
+		slog.Warn("Could not open path", "path", path, "error", err)
+		return
+	}
+	go cmd.Wait()
+}
@@ -0,0 +1,19 @@
+//go:build !windows
+
+package main
+
+// consoleWindowSupported reports whether the console window can be shown and hidden.
+// Terminals on other platforms belong to the user, so they are left alone.
+func consoleWindowSupported() bool {
+	return false
+}
+
+// hideConsoleWindow is a no-op outside Windows
+func hideConsoleWindow() bool {
+	return false
+}
+
+// showConsoleWindow is a no-op outside Windows
+func showConsoleWindow(show bool) bool {
+	return false
+}
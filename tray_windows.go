@@ -0,0 +1,37 @@
+//go:build windows
+
+package main
+
+var (
+	procGetConsoleWindow = kernel32.NewProc("GetConsoleWindow")
+	procShowWindow       = user32.NewProc("ShowWindow")
+)
+
+const (
+	swHide = 0
+	swShow = 5
+)
+
+// consoleWindowSupported reports whether the console window can be shown and hidden
+func consoleWindowSupported() bool {
+	return true
+}
+
+// hideConsoleWindow hides the console window and reports whether there was one to hide
+func hideConsoleWindow() bool {
+	return showConsoleWindow(false)
+}
+
+// showConsoleWindow shows or hides the console window and reports whether there is one
+func showConsoleWindow(show bool) bool {
+	hwnd, _, _ := procGetConsoleWindow.Call()
+	if hwnd == 0 {
+		return false
+	}
+	cmd := swHide
+	if show {
+		cmd = swShow
+	}
+	procShowWindow.Call(hwnd, uintptr(cmd))
+	return true
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Alarm modes
+const (
+	alarmModeSound       = "sound"
+	alarmModeSpeech      = "speech"
+	alarmModeSoundSpeech = "sound+speech"
+)
+
+// defaultSpeechMessage is spoken when speech_message is not set
+const defaultSpeechMessage = "You haven't saved for {elapsed}."
+
+// TTSBackend speaks text aloud at a volume level (0-100). Speak returns once
+// the text has been spoken, or as soon as stop is closed.
+type TTSBackend interface {
+	Name() string
+	Speak(text string, volume int, stop <-chan struct{}) error
+}
+
+// newTTSBackend creates the backend named in the config. "auto" picks SAPI on
+// Windows, and on Linux piper (if a voice model is configured) or espeak-ng/espeak.
+func newTTSBackend(config Config, audio *audioPlayer) (TTSBackend, error) {
+	backend := strings.ToLower(config.TTSBackend)
+	if backend == "" || backend == "auto" {
+		switch {
+		case runtime.GOOS == "windows":
+			backend = "sapi"
+		case config.PiperModel != "" && commandExists("piper"):
+			backend = "piper"
+		default:
+			backend = "espeak"
+		}
+	}
+
+	switch backend {
+	case "sapi":
+		if runtime.GOOS != "windows" {
+			return nil, fmt.Errorf("SAPI text-to-speech is only available on Windows")
+		}
+		return sapiBackend{audio: audio}, nil
+	case "espeak", "espeak-ng":
+		// Prefer espeak-ng, the maintained fork, when both are installed
+		for _, binary := range []string{"espeak-ng", "espeak"} {
+			if commandExists(binary) {
+				return espeakBackend{binary: binary}, nil
+			}
+		}
+		return nil, fmt.Errorf("neither espeak-ng nor espeak was found in PATH")
+	case "piper":
+		if !commandExists("piper") {
+			return nil, fmt.Errorf("piper was not found in PATH")
+		}
+		if config.PiperModel == "" {
+			return nil, fmt.Errorf("piper_model must be set to use the piper backend")
+		}
+		return piperBackend{model: resolveModelPath(config.PiperModel), audio: audio}, nil
+	default:
+		return nil, fmt.Errorf("unknown tts_backend %q (use auto, sapi, espeak or piper)", config.TTSBackend)
+	}
+}
+
+// runSpeechCommand runs a speech engine and returns its output. The engine is
+// killed as soon as stop is closed, which ends the speech mid-sentence.
+func runSpeechCommand(cmd *exec.Cmd, stop <-chan struct{}) ([]byte, error) {
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+	select {
+	case err := <-exited:
+		return output.Bytes(), err
+	case <-stop:
+		cmd.Process.Kill()
+		<-exited
+		return output.Bytes(), nil
+	}
+}
+
+// commandExists reports whether an executable can be found in PATH
+func commandExists(name string) bool {
+	_, err := exec.LookPath(name)
+	return err == nil
+}
+
+// espeakBackend speaks through espeak-ng or espeak
+type espeakBackend struct {
+	binary string
+}
+
+func (b espeakBackend) Name() string {
+	return b.binary
+}
+
+func (b espeakBackend) Speak(text string, volume int, stop <-chan struct{}) error {
+	// -a is amplitude (0-200, default 100); --stdin avoids quoting the message
+	cmd := exec.Command(b.binary, "-a", fmt.Sprint(volume), "--stdin")
+	cmd.Stdin = strings.NewReader(text)
+	if output, err := runSpeechCommand(cmd, stop); err != nil {
+		return fmt.Errorf("%s failed: %v %s", b.binary, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// piperBackend renders speech with piper and plays it through the audio player,
+// so it goes to the same output device and honours the alarm volume
+type piperBackend struct {
+	model string
+	audio *audioPlayer
+}
+
+func (b piperBackend) Name() string {
+	return "piper"
+}
+
+func (b piperBackend) Speak(text string, volume int, stop <-chan struct{}) error {
+	wavPath, err := speechTempFile()
+	if err != nil {
+		return err
+	}
+	defer os.Remove(wavPath)
+
+	cmd := exec.Command("piper", "--model", b.model, "--output_file", wavPath)
+	cmd.Stdin = strings.NewReader(text)
+	if output, err := runSpeechCommand(cmd, stop); err != nil {
+		return fmt.Errorf("piper failed: %v %s", err, strings.TrimSpace(string(output)))
+	}
+	return playSpeechFile(b.audio, wavPath, volume, stop)
+}
+
+// speechTempFile returns the path of a new, empty temp file for rendered speech
+func speechTempFile() (string, error) {
+	tmp, err := os.CreateTemp("", "nwn2-reminder-*.wav")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file: %v", err)
+	}
+	tmp.Close()
+	return tmp.Name(), nil
+}
+
+// playSpeechFile plays speech rendered to a WAV file through the audio player,
+// unless it was stopped while rendering
+func playSpeechFile(audio *audioPlayer, wavPath string, volume int, stop <-chan struct{}) error {
+	select {
+	case <-stop:
+		return nil
+	default:
+	}
+
+	buffer, err := decodeSound(wavPath)
+	if err != nil {
+		return err
+	}
+	return audio.playStreamer(audio.streamer(newSound(buffer), volume), stop)
+}
+
+// sapiBackend speaks through the Windows Speech API via PowerShell. SAPI
+// itself always speaks on the default device, so with audio_device set the
+// speech is rendered to a file and played through the audio player instead.
+type sapiBackend struct {
+	audio *audioPlayer
+}
+
+func (sapiBackend) Name() string {
+	return "SAPI"
+}
+
+func (b sapiBackend) Speak(text string, volume int, stop <-chan struct{}) error {
+	if b.audio != nil && b.audio.choosesDevice() {
+		return b.speakToDevice(text, volume, stop)
+	}
+	// The message is passed on stdin so it never needs escaping for PowerShell
+	script := "Add-Type -AssemblyName System.Speech; " +
+		"$s = New-Object System.Speech.Synthesis.SpeechSynthesizer; " +
+		fmt.Sprintf("$s.Volume = %d; ", volume) +
+		"$s.Speak([Console]::In.ReadToEnd())"
+	cmd := exec.Command("powershell", "-NoProfile", "-Command", script)
+	cmd.Stdin = strings.NewReader(text)
+	if output, err := runSpeechCommand(cmd, stop); err != nil {
+		return fmt.Errorf("SAPI speech failed: %v %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// speakToDevice renders the speech to a WAV file at full volume and plays it
+// through the audio player, which applies the volume
+func (b sapiBackend) speakToDevice(text string, volume int, stop <-chan struct{}) error {
+	wavPath, err := speechTempFile()
+	if err != nil {
+		return err
+	}
+	defer os.Remove(wavPath)
+
+	// Single quotes in a PowerShell string literal are escaped by doubling them
+	script := "Add-Type -AssemblyName System.Speech; " +
+		"$s = New-Object System.Speech.Synthesis.SpeechSynthesizer; " +
+		fmt.Sprintf("$s.SetOutputToWaveFile('%s'); ", strings.ReplaceAll(wavPath, "'", "''")) +
+		"$s.Speak([Console]::In.ReadToEnd()); $s.Dispose()"
+	cmd := exec.Command("powershell", "-NoProfile", "-Command", script)
+	cmd.Stdin = strings.NewReader(text)
+	if output, err := runSpeechCommand(cmd, stop); err != nil {
+		return fmt.Errorf("SAPI speech failed: %v %s", err, strings.TrimSpace(string(output)))
+	}
+	return playSpeechFile(b.audio, wavPath, volume, stop)
+}
+
+// formatSpeechMessage fills in the placeholders of a speech message template:
+// {elapsed} (e.g. "12 minutes"), {minutes} (e.g. "12") and {time} (e.g. "21:45")
+func formatSpeechMessage(template string, elapsed time.Duration) string {
+	if template == "" {
+		template = defaultSpeechMessage
+	}
+	replacer := strings.NewReplacer(
+		"{elapsed}", spokenDuration(elapsed),
+		"{minutes}", fmt.Sprint(int(elapsed.Minutes())),
+		"{time}", time.Now().Format("15:04"),
+	)
+	return replacer.Replace(template)
+}
+
+// spokenDuration formats a duration the way you'd say it, e.g. "1 hour and 5 minutes"
+func spokenDuration(d time.Duration) string {
+	plural := func(n int, unit string) string {
+		if n == 1 {
+			return fmt.Sprintf("1 %s", unit)
+		}
+		return fmt.Sprintf("%d %s", n, unit+"s")
+	}
+
+	if d < time.Minute {
+		return plural(int(d.Seconds()), "second")
+	}
+	hours := int(d.Hours())
+	minutes := int(d.Minutes()) % 60
+	switch {
+	case hours == 0:
+		return plural(minutes, "minute")
+	case minutes == 0:
+		return plural(hours, "hour")
+	default:
+		return plural(hours, "hour") + " and " + plural(minutes, "minute")
+	}
+}
+
+// speakReminder speaks the reminder message for the alarm of generation gen
+// and reports whether it was spoken. While it speaks it is the current alarm
+// playback, so a save, snooze or acknowledge cuts it off, as does
+// alarm_max_duration; nothing is said if that happened before it started.
+func (sr *SaveReminder) speakReminder(gen uint64, elapsed time.Duration, volume int) bool {
+	if volume == 0 {
+		return true
+	}
+	sr.mu.Lock()
+	if !sr.alarmCurrentLocked(gen) {
+		sr.mu.Unlock()
+		return true
+	}
+	if sr.tts == nil {
+		backend, err := newTTSBackend(sr.config, sr.audio)
+		if err != nil {
+			sr.mu.Unlock()
+			slog.Warn("Text-to-speech unavailable", "error", err)
+			return false
+		}
+		sr.tts = backend
+	}
+	tts := sr.tts
+	p := newPlayback()
+	sr.stopPlaybackLocked()
+	sr.playback = p
+	sr.mu.Unlock()
+	defer close(p.done)
+	if maxDuration := sr.playbackOptions().maxDuration; maxDuration > 0 {
+		timer := time.AfterFunc(maxDuration, p.Stop)
+		defer timer.Stop()
+	}
+
+	message := formatSpeechMessage(sr.config.SpeechMessage, elapsed)
+	slog.Info("Speaking reminder", "backend", tts.Name(), "message", message)
+	err := tts.Speak(message, volume, p.stop)
+	if p.Stopped() {
+		slog.Debug("Spoken reminder cut off")
+		return true
+	}
+	if err != nil {
+		slog.Warn("Text-to-speech failed", "error", err)
+		return false
+	}
+	return true
+}
+
+// resolveModelPath resolves a piper voice model path relative to the executable directory
+func resolveModelPath(path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	if candidate := filepath.Join(getExecutableDir(), path); fileExists(candidate) {
+		return candidate
+	}
+	return path
+}
+
+// fileExists reports whether a path exists
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,489 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
+)
+
+const (
+	// tuiRecentSaves is how many recent saves the TUI shows
+	tuiRecentSaves = 5
+	// tuiLogLines is how many log lines are kept for the log pane
+	tuiLogLines = 200
+	// tuiBackupRefresh is how often the backups list is re-read from disk
+	tuiBackupRefresh = 10 * time.Second
+)
+
+// tuiHelp is the key help shown at the bottom of the screen
+const tuiHelp = "↑/↓ select  Enter restore  p pin  n note  c checkpoint  d delete  s snooze  a ack  r resume  q quit"
+
+// logRing keeps the most recent log lines so the TUI can show them
+type logRing struct {
+	mu      sync.Mutex
+	lines   []string
+	partial string
+	changed chan struct{}
+}
+
+func newLogRing() *logRing {
+	return &logRing{changed: make(chan struct{}, 1)}
+}
+
+func (r *logRing) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	text := r.partial + string(p)
+	lines := strings.Split(text, "\n")
+	r.partial = lines[len(lines)-1]
+	r.lines = append(r.lines, lines[:len(lines)-1]...)
+	if len(r.lines) > tuiLogLines {
+		r.lines = r.lines[len(r.lines)-tuiLogLines:]
+	}
+	r.mu.Unlock()
+
+	// Wake the TUI without blocking the logger
+	select {
+	case r.changed <- struct{}{}:
+	default:
+	}
+	return len(p), nil
+}
+
+// last returns up to n of the most recent lines, oldest first
+func (r *logRing) last(n int) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if n > len(r.lines) {
+		n = len(r.lines)
+	}
+	return append([]string(nil), r.lines[len(r.lines)-n:]...)
+}
+
+// tuiConfirm is an action waiting for the user to press y
+type tuiConfirm struct {
+	prompt string
+	action func() error
+}
+
+// tuiInput is a line of text being typed into the footer
+type tuiInput struct {
+	prompt string
+	text   []rune
+	action func(text string) error
+}
+
+// tui is the full-screen terminal interface started with run --tui
+type tui struct {
+	sr      *SaveReminder
+	screen  tcell.Screen
+	logs    *logRing
+	backups []backupInfo
+	loaded  time.Time // When backups was last read
+	newest  time.Time // Newest save event when backups was last read
+	cursor  int       // Selected backup
+	offset  int       // First backup shown in the list
+	confirm *tuiConfirm
+	input   *tuiInput
+	message string // One-off message shown in the footer until the next key press
+}
+
+// runTUI shows the terminal interface and blocks until the user quits or stop receives a signal.
+// While it runs, log output goes to the TUI's log pane instead of the console.
+func (sr *SaveReminder) runTUI(stop <-chan os.Signal) error {
+	screen, err := tcell.NewScreen()
+	if err != nil {
+		return fmt.Errorf("failed to open terminal: %v", err)
+	}
+	if err := screen.Init(); err != nil {
+		return fmt.Errorf("failed to initialize terminal: %v", err)
+	}
+
+	t := &tui{sr: sr, screen: screen, logs: newLogRing()}
+	setConsoleOutput(t.logs)
+	defer func() {
+		screen.Fini()
+		setConsoleOutput(os.Stderr)
+		// Leave the last log lines on the console, so the shutdown messages follow on
+		for _, line := range t.logs.last(10) {
+			fmt.Fprintln(os.Stderr, line)
+		}
+	}()
+
+	events := make(chan tcell.Event)
+	quit := make(chan struct{})
+	defer close(quit)
+	go screen.ChannelEvents(events, quit)
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	t.reloadBackups()
+	for {
+		t.draw()
+		select {
+		case <-stop:
+			return nil
+		case <-ticker.C:
+		case <-t.logs.changed:
+		case ev := <-events:
+			switch ev := ev.(type) {
+			case *tcell.EventKey:
+				if !t.handleKey(ev) {
+					return nil
+				}
+			case *tcell.EventResize:
+				screen.Sync()
+			}
+		}
+	}
+}
+
+// reloadBackups re-reads the backups list, keeping the selected backup selected
+func (t *tui) reloadBackups() {
+	var selected string
+	if t.cursor < len(t.backups) {
+		selected = t.backups[t.cursor].Name
+	}
+
+	backups, err := listBackups(t.sr.backupsPath)
+	if err != nil {
+		slog.Warn("Could not list backups", "error", err)
+	}
+	t.backups = backups
+	t.loaded = time.Now()
+	t.newest = t.sr.newestSaveEvent()
+
+	t.cursor = 0
+	for i, b := range backups {
+		if b.Name == selected {
+			t.cursor = i
+			break
+		}
+	}
+}
+
+// handleKey handles a key press and returns false when the TUI should exit
+func (t *tui) handleKey(ev *tcell.EventKey) bool {
+	t.message = ""
+	if t.confirm != nil {
+		confirm := t.confirm
+		t.confirm = nil
+		if ev.Key() == tcell.KeyRune && (ev.Rune() == 'y' || ev.Rune() == 'Y') {
+			if err := confirm.action(); err != nil {
+				slog.Error("Backup action failed", "error", err)
+				t.message = "Error: " + err.Error()
+			}
+			t.reloadBackups()
+		} else {
+			t.message = "Cancelled"
+		}
+		return true
+	}
+	if t.input != nil {
+		t.handleInputKey(ev)
+		return true
+	}
+
+	switch ev.Key() {
+	case tcell.KeyCtrlC, tcell.KeyEscape:
+		return false
+	case tcell.KeyUp:
+		t.cursor--
+	case tcell.KeyDown:
+		t.cursor++
+	case tcell.KeyPgUp:
+		t.cursor -= t.listHeight()
+	case tcell.KeyPgDn:
+		t.cursor += t.listHeight()
+	case tcell.KeyHome:
+		t.cursor = 0
+	case tcell.KeyEnd:
+		t.cursor = len(t.backups) - 1
+	case tcell.KeyEnter:
+		t.askRestore()
+	case tcell.KeyDelete:
+		t.askDelete()
+	case tcell.KeyRune:
+		switch ev.Rune() {
+		case 'q', 'Q':
+			return false
+		case 'k':
+			t.cursor--
+		case 'j':
+			t.cursor++
+		case 'p':
+			t.togglePin()
+		case 'n':
+			t.editNote()
+		case 'c':
+			t.message, _ = t.sr.handleControlCommand("checkpoint", "tui")
+			t.reloadBackups()
+		case 'd':
+			t.askDelete()
+		case 's':
+			t.message, _ = t.sr.handleControlCommand("snooze", "tui")
+		case 'a':
+			t.message, _ = t.sr.handleControlCommand("ack", "tui")
+		case 'r':
+			t.message, _ = t.sr.handleControlCommand("resume", "tui")
+		}
+	}
+	if t.cursor >= len(t.backups) {
+		t.cursor = len(t.backups) - 1
+	}
+	if t.cursor < 0 {
+		t.cursor = 0
+	}
+	return true
+}
+
+// selectedBackup returns the highlighted backup, if there is one
+func (t *tui) selectedBackup() (backupInfo, bool) {
+	if t.cursor < 0 || t.cursor >= len(t.backups) {
+		return backupInfo{}, false
+	}
+	return t.backups[t.cursor], true
+}
+
+func (t *tui) askRestore() {
+	b, ok := t.selectedBackup()
+	if !ok {
+		return
+	}
+	t.confirm = &tuiConfirm{
+		prompt: fmt.Sprintf("Restore %s over the current quicksave? (y/n)", b.Name),
+		action: func() error { return t.sr.restoreBackup(b) },
+	}
+}
+
+func (t *tui) askDelete() {
+	b, ok := t.selectedBackup()
+	if !ok {
+		return
+	}
+	if b.Pinned {
+		t.message = "Backup is pinned; press p to unpin it first"
+		return
+	}
+	t.confirm = &tuiConfirm{
+		prompt: fmt.Sprintf("Delete %s? (y/n)", b.Name),
+		action: func() error { return t.sr.deleteBackup(b) },
+	}
+}
+
+func (t *tui) togglePin() {
+	b, ok := t.selectedBackup()
+	if !ok {
+		return
+	}
+	if err := setBackupPinned(b, !b.Pinned); err != nil {
+		slog.Error("Pinning backup failed", "error", err)
+		t.message = "Error: " + err.Error()
+	} else {
+		t.sr.replicateMetadata(b.Name)
+	}
+	t.reloadBackups()
+}
+
+func (t *tui) editNote() {
+	b, ok := t.selectedBackup()
+	if !ok {
+		return
+	}
+	t.input = &tuiInput{
+		prompt: "Note (Enter to save, Esc to cancel): ",
+		text:   []rune(b.Note),
+		action: func(text string) error {
+			if err := setBackupNote(b, text); err != nil {
+				return err
+			}
+			t.sr.replicateMetadata(b.Name)
+			return nil
+		},
+	}
+}
+
+// handleInputKey edits the text being typed, and runs its action on Enter
+func (t *tui) handleInputKey(ev *tcell.EventKey) {
+	input := t.input
+	switch ev.Key() {
+	case tcell.KeyEscape, tcell.KeyCtrlC:
+		t.input = nil
+		t.message = "Cancelled"
+	case tcell.KeyEnter:
+		t.input = nil
+		if err := input.action(string(input.text)); err != nil {
+			slog.Error("Backup action failed", "error", err)
+			t.message = "Error: " + err.Error()
+		}
+		t.reloadBackups()
+	case tcell.KeyBackspace, tcell.KeyBackspace2:
+		if len(input.text) > 0 {
+			input.text = input.text[:len(input.text)-1]
+		}
+	case tcell.KeyCtrlU:
+		input.text = nil
+	case tcell.KeyRune:
+		input.text = append(input.text, ev.Rune())
+	}
+}
+
+// logHeight is the number of rows of the log pane
+func (t *tui) logHeight() int {
+	_, height := t.screen.Size()
+	if height < 24 {
+		return 3
+	}
+	return height / 4
+}
+
+// listHeight is the number of rows available to the backups list. The rest of
+// the screen is the title, status, recent saves and log panes with their
+// headers and spacers, and the footer.
+func (t *tui) listHeight() int {
+	_, height := t.screen.Size()
+	rows := height - 15 - t.logHeight()
+	if rows < 1 {
+		return 1
+	}
+	return rows
+}
+
+// draw renders the whole screen
+func (t *tui) draw() {
+	t.sr.mu.Lock()
+	first := len(t.sr.saveEvents) - tuiRecentSaves
+	if first < 0 {
+		first = 0
+	}
+	saves := append([]saveEvent(nil), t.sr.saveEvents[first:]...)
+	lastSave := t.sr.lastSaveTime
+	t.sr.mu.Unlock()
+
+	if len(saves) > 0 && saves[len(saves)-1].Time.After(t.newest) || time.Since(t.loaded) >= tuiBackupRefresh {
+		t.reloadBackups()
+	}
+
+	s := t.screen
+	s.Clear()
+	width, height := s.Size()
+	plain := tcell.StyleDefault
+	bold := plain.Bold(true)
+	dim := plain.Dim(true)
+	row := 0
+
+	// Title bar
+	title := tcell.StyleDefault.Reverse(true)
+	t.fill(row, title)
+	t.text(1, row, "NWN2 Save Reminder", title.Bold(true))
+	clock := time.Now().Format("15:04:05")
+	t.text(width-len(clock)-1, row, clock, title)
+	row += 2
+
+	// Countdown, in the same colors as the tray icon
+	state, status := t.sr.trayStatus()
+	c := trayColors[state]
+	t.text(1, row, status, bold.Foreground(tcell.NewRGBColor(int32(c.R), int32(c.G), int32(c.B))))
+	row++
+	t.text(1, row, fmt.Sprintf("Last save at %s (%s ago)", lastSave.Format("15:04:05"), formatCountdown(time.Since(lastSave))), dim)
+	row += 2
+
+	// Recent saves, newest first
+	t.text(1, row, "Recent saves", bold)
+	row++
+	if len(saves) == 0 {
+		t.text(3, row, "(no saves yet)", dim)
+	}
+	for i := len(saves) - 1; i >= 0; i-- {
+		e := saves[i]
+		line := fmt.Sprintf("%s  backed up to %s", e.Time.Format("15:04:05"), e.Backup)
+		if e.Err != nil {
+			line = fmt.Sprintf("%s  backup failed: %v", e.Time.Format("15:04:05"), e.Err)
+		}
+		t.text(3, row+len(saves)-1-i, line, plain)
+	}
+	row += tuiRecentSaves + 1
+
+	// Backups list
+	listHeight := t.listHeight()
+	t.text(1, row, fmt.Sprintf("Backups (%d)", len(t.backups)), bold)
+	row++
+	if t.cursor < t.offset {
+		t.offset = t.cursor
+	}
+	if t.cursor >= t.offset+listHeight {
+		t.offset = t.cursor - listHeight + 1
+	}
+	if len(t.backups) == 0 {
+		t.text(3, row, "(no backups yet)", dim)
+	}
+	for i := 0; i < listHeight && t.offset+i < len(t.backups); i++ {
+		b := t.backups[t.offset+i]
+		style := plain
+		if b.Pinned {
+			style = bold
+		}
+		if t.offset+i == t.cursor {
+			style = style.Reverse(true)
+			t.fill(row+i, style)
+		}
+		pin := "      "
+		if b.Pinned {
+			pin = "pinned"
+		}
+		line := fmt.Sprintf("%s  %s  %s", b.Time.Format("2006-01-02 15:04:05"), pin, b.Label)
+		if b.Note != "" {
+			line += "  " + b.Note
+		}
+		t.text(3, row+i, line, style)
+	}
+	row += listHeight + 1
+
+	// Log pane
+	logHeight := t.logHeight()
+	for i, line := range t.logs.last(logHeight) {
+		t.text(1, row+i, line, dim)
+	}
+
+	// Footer: a pending question, a message or the key help
+	footer := tuiHelp
+	footerStyle := dim
+	switch {
+	case t.input != nil:
+		footer, footerStyle = t.input.prompt+string(t.input.text)+"_", bold
+	case t.confirm != nil:
+		footer, footerStyle = t.confirm.prompt, bold
+	case t.message != "":
+		footer, footerStyle = t.message, bold
+	}
+	t.text(1, height-1, footer, footerStyle)
+
+	s.Show()
+}
+
+// text draws a string at a position, cut off at the right edge of the screen
+func (t *tui) text(x, y int, s string, style tcell.Style) {
+	width, _ := t.screen.Size()
+	for _, r := range s {
+		w := runewidth.RuneWidth(r)
+		if x+w > width {
+			return
+		}
+		t.screen.SetContent(x, y, r, nil, style)
+		x += w
+	}
+}
+
+// fill paints a whole row in a style
+func (t *tui) fill(y int, style tcell.Style) {
+	width, _ := t.screen.Size()
+	for x := 0; x < width; x++ {
+		t.screen.SetContent(x, y, ' ', nil, style)
+	}
+}
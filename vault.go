@@ -0,0 +1,228 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// vaultLabelPrefix starts the label of every character vault backup
+const vaultLabelPrefix = "vault - "
+
+// VaultBackupConfig sets up backups of the character files in the vaults
+type VaultBackupConfig struct {
+	Enabled      bool     `json:"enabled"`        // Back up each character file the game writes to a vault
+	Paths        []string `json:"paths"`          // Vault folders, relative to the NWN2 user folder
+	CountsAsSave bool     `json:"counts_as_save"` // A character file write resets the alarm like a quicksave
+}
+
+// defaultVaultPaths are the vaults of single-player, local and hosted games
+func defaultVaultPaths() []string {
+	return []string{"localvault", "servervault"}
+}
+
+// vaultWatcher backs up character files as the game writes them. Server
+// vaults keep a subfolder per player account, so those are watched as well.
+type vaultWatcher struct {
+	sr      *SaveReminder
+	watcher *fsnotify.Watcher
+	roots   []string
+
+	mu       sync.Mutex
+	timers   map[string]*time.Timer // Pending backup per character file
+	lastHash map[string][32]byte    // Contents of each character file when it was last backed up
+}
+
+// watchVaults starts watching the configured vault folders. Folders that
+// don't exist yet are skipped, as the game creates them when first used.
+func (sr *SaveReminder) watchVaults() error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create vault watcher: %v", err)
+	}
+	v := &vaultWatcher{
+		sr:       sr,
+		watcher:  watcher,
+		timers:   make(map[string]*time.Timer),
+		lastHash: make(map[string][32]byte),
+	}
+
+	userFolder := nwn2UserFolder(sr.savesPath)
+	for _, path := range sr.config.VaultBackup.Paths {
+		root := filepath.Join(userFolder, path)
+		if _, err := os.Stat(root); err != nil {
+			slog.Debug("Skipping vault folder", "path", root, "error", err)
+			continue
+		}
+		v.roots = append(v.roots, root)
+		v.add(root)
+		entries, err := os.ReadDir(root)
+		if err != nil {
+			slog.Warn("Could not read vault folder", "path", root, "error", err)
+			continue
+		}
+		for _, entry := range entries {
+			if entry.IsDir() {
+				v.add(filepath.Join(root, entry.Name()))
+			}
+		}
+	}
+	if len(v.roots) == 0 {
+		watcher.Close()
+		return fmt.Errorf("none of the vault folders exist in %s", userFolder)
+	}
+
+	go v.run()
+	return nil
+}
+
+func (v *vaultWatcher) add(folder string) {
+	if err := v.watcher.Add(folder); err != nil {
+		slog.Warn("Failed to watch vault folder", "path", folder, "error", err)
+		return
+	}
+	slog.Info("Watching vault folder for character changes", "path", folder)
+}
+
+func (v *vaultWatcher) run() {
+	defer v.watcher.Close()
+	for {
+		select {
+		case <-v.sr.done:
+			return
+		case event, ok := <-v.watcher.Events:
+			if !ok {
+				return
+			}
+			v.handle(event)
+		case err, ok := <-v.watcher.Errors:
+			if !ok {
+				return
+			}
+			slog.Error("Vault watcher error", "error", err)
+		}
+	}
+}
+
+// handle reacts to a change in a vault: new account folders are watched too,
+// and written character files are backed up once the game is done with them
+func (v *vaultWatcher) handle(event fsnotify.Event) {
+	if event.Op&fsnotify.Write == 0 && event.Op&fsnotify.Create == 0 {
+		return
+	}
+	if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+		for _, root := range v.roots {
+			if filepath.Dir(event.Name) == root {
+				v.add(event.Name)
+			}
+		}
+		return
+	}
+	if !strings.EqualFold(filepath.Ext(event.Name), ".bic") {
+		return
+	}
+
+	debounceDelay, err := time.ParseDuration(v.sr.config.DebounceDelay)
+	if err != nil {
+		debounceDelay = 3 * time.Second
+	}
+	path := event.Name
+	v.mu.Lock()
+	if timer, ok := v.timers[path]; ok {
+		timer.Stop()
+	}
+	v.timers[path] = time.AfterFunc(debounceDelay, func() { v.backup(path) })
+	v.mu.Unlock()
+	slog.Debug("Character file changed, waiting before backing it up", "file", path, "delay", debounceDelay)
+}
+
+// backup copies a character file into a "vault - <character name>" backup,
+// unless it's unchanged since its last backup
+func (v *vaultWatcher) backup(path string) {
+	v.mu.Lock()
+	delete(v.timers, path)
+	v.mu.Unlock()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		// Deleted or renamed in the meantime
+		slog.Debug("Character file no longer readable, skipping backup", "file", path, "error", err)
+		return
+	}
+	hash := sha256.Sum256(data)
+	v.mu.Lock()
+	unchanged := v.lastHash[path] == hash
+	v.mu.Unlock()
+	if unchanged {
+		slog.Debug("Character file unchanged, skipping backup", "file", path)
+		return
+	}
+
+	character, err := gffCharacterName(data)
+	if err != nil || character == "" {
+		character = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	name, backupPath, err := v.sr.createVaultBackup(filepath.Base(path), data, character)
+	if err != nil {
+		slog.Error("Character backup failed", "file", path, "error", err)
+		v.sr.recordHistory(historyEvent{Type: historyBackupFailed, Error: err.Error()})
+		return
+	}
+	v.mu.Lock()
+	v.lastHash[path] = hash
+	v.mu.Unlock()
+	slog.Info("Character file backed up", "character", character, "file", path, "backup", name)
+
+	if v.sr.config.VaultBackup.CountsAsSave {
+		v.sr.markSaved(name, backupPath)
+		slog.Info("Character save counts as a save. Alarm timer reset.")
+	}
+}
+
+// createVaultBackup stores a character file in a new backup folder and
+// returns the folder's name and path
+func (sr *SaveReminder) createVaultBackup(fileName string, data []byte, character string) (string, string, error) {
+	label := safeFileName(character)
+	if label == "" {
+		label = "character"
+	}
+	name, partial, err := sr.claimBackupName(fmt.Sprintf("%s - %s%s", time.Now().Format(backupTimestampFormat), vaultLabelPrefix, label))
+	if err != nil {
+		return "", "", err
+	}
+	if err := os.WriteFile(filepath.Join(partial, fileName), data, 0644); err != nil {
+		os.RemoveAll(partial)
+		return "", "", fmt.Errorf("error writing backup file: %v", err)
+	}
+	dest := filepath.Join(sr.backupsPath, name)
+	if err := os.Rename(partial, dest); err != nil {
+		os.RemoveAll(partial)
+		return "", "", fmt.Errorf("error finishing backup: %v", err)
+	}
+	sr.backupAdded(dest)
+
+	if sr.replicator != nil {
+		sr.replicator.enqueue(name)
+	}
+	sr.pruneBackups()
+	return name, dest, nil
+}
+
+// safeFileName replaces the characters Windows doesn't allow in file names
+func safeFileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r < 32 || strings.ContainsRune(`<>:"/\|?*`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	// Windows also drops trailing dots and spaces
+	return strings.TrimRight(name, ". ")
+}